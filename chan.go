@@ -0,0 +1,174 @@
+package nqueue
+
+import (
+	"context"
+	"iter"
+)
+
+// FeedFrom 函数把通道 ch 中的元素依次放入队列 q，是 Chan 的反向适配。
+// 入队通过 EnqueueContext 完成，有界队列已满时阻塞等待空位，而不是忙等。
+// ch 被关闭时返回 nil；q 被关闭时返回 ErrQueueClosed，此时若已从 ch 取出一个元素，该元素会被丢弃。
+// 无论哪一方先关闭，FeedFrom 都会返回，不会遗留 goroutine。
+func FeedFrom[T any](q Queue[T], ch <-chan T) error {
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := q.EnqueueContext(context.Background(), v); err != nil {
+				return err
+			}
+		case <-q.Done():
+			return ErrQueueClosed
+		}
+	}
+}
+
+// chanConfig 是 Chan 适配器的配置。
+type chanConfig struct {
+	buffer int // 返回的通道的缓冲区大小。
+	batch  int // 转发 goroutine 每次从队列中取出的最大元素数量。
+}
+
+// ChanOption 是 Chan 的可选配置项。
+type ChanOption func(c *chanConfig)
+
+// WithChanBuffer 选项设置 Chan 返回的通道的缓冲区大小，默认为 0（无缓冲）。
+func WithChanBuffer(n int) ChanOption {
+	return func(c *chanConfig) {
+		c.buffer = max(n, 0)
+	}
+}
+
+// WithChanBatch 选项设置转发 goroutine 每次从队列中批量取出的最大元素数量，默认为 1。
+// 较大的批量可以减少高吞吐下每个元素的加锁开销，通常与 WithChanBuffer 一起使用。
+func WithChanBatch(n int) ChanOption {
+	return func(c *chanConfig) {
+		c.batch = max(n, 1)
+	}
+}
+
+// Chan 方法返回一个通道，队列中的元素会被一个转发 goroutine 按先进先出的顺序依次发送到该通道，
+// 便于在 select 语句中使用。队列关闭且所有元素都已发送后，通道会被关闭，已出队的元素不会被丢弃。
+// 转发 goroutine 在通道上阻塞发送，调用方应一直读取直到通道关闭，否则该 goroutine 不会退出。
+func (q *NQueue[T]) Chan(opts ...ChanOption) <-chan T {
+	c := chanConfig{batch: 1}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	ch := make(chan T, c.buffer)
+	go func() {
+		defer close(ch)
+		for {
+			ts, _, _ := q.DequeueBatchWait(context.Background(), c.batch)
+			if len(ts) == 0 {
+				return // 队列已关闭且为空。
+			}
+			for _, t := range ts {
+				ch <- t
+			}
+		}
+	}()
+	return ch
+}
+
+// ToChan 方法与 Chan 相同，但在 ctx 结束时停止转发并关闭返回的通道，队列本身不会被关闭。
+// 转发 goroutine 因 ctx 结束而没能发送出去的元素会被放回队列头部，不会丢失，也不计为一次重新投递；
+// 因此调用方在 ctx 结束后可以停止读取，转发 goroutine 仍会退出。
+// 等待发送的元素与 DequeueAck 取出的元素一样计入 InFlight()，WaitDrain 和 CloseAndDrain 会等待它被发送或放回。
+func (q *NQueue[T]) ToChan(ctx context.Context) <-chan T {
+	ch := make(chan T)
+	go func() {
+		defer close(ch)
+		for {
+			n, err := q.hold(ctx)
+			if err != nil || n == nil {
+				return // ctx 已结束，或队列已关闭且为空。
+			}
+			select {
+			case ch <- n.value:
+				q.settle(n)
+			case <-ctx.Done():
+				q.unget(n)
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// hold 方法与 DequeueContext 相同地等待并取出头部节点，但把它计入未确认数量，直到 settle 或 unget。
+// 队列已关闭且为空时返回 nil。
+func (q *NQueue[T]) hold(ctx context.Context) (*node[T], error) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	if err := q.waitWithContext(ctx, q.recvCond, q.canDequeue); err != nil {
+		return nil, err
+	}
+	if q.head == nil {
+		return nil, nil
+	}
+	q.inflight++ // 先计入未确认数量，避免 popNode 误判队列已被取空。
+	return q.popNode(), nil
+}
+
+// settle 方法在 hold 取出的节点 n 被发送后回收它。
+func (q *NQueue[T]) settle(n *node[T]) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	q.inflight--
+	q.recycle(n)
+	if q.isEmpty() {
+		q.emptied()
+	}
+}
+
+// unget 方法把 hold 取出但没有发送出去的节点 n 放回队列头部，节点的标签、投递次数和入队时间保持不变。
+func (q *NQueue[T]) unget(n *node[T]) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	q.inflight--
+	q.pushFrontChain(n)
+}
+
+// FromChan 方法把通道 ch 中的元素依次放入队列，直到 ch 或队列被关闭，等价于 FeedFrom(q, ch)。
+func (q *NQueue[T]) FromChan(ch <-chan T) error {
+	return FeedFrom[T](q, ch)
+}
+
+// All 方法返回一个迭代器，按先进先出的顺序不断出队元素，没有元素时阻塞等待，队列关闭且取空后结束：
+//
+//	for v := range q.All() {
+//		handle(v)
+//	}
+//
+// 提前退出循环时，已经交给循环体的元素都已出队，其余元素留在队列中。
+func (q *NQueue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			t, ok, _ := q.DequeueWait()
+			if !ok || !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// PipeTo 方法把队列中的元素按先进先出的顺序依次发送到调用方提供的通道 dst，
+// 直到队列关闭且所有元素都已发送后返回。与 Chan 不同，dst 由调用方创建和关闭，PipeTo 不会关闭它。
+// 发送是阻塞的，dst 的读取速度会反压到队列上。
+func (q *NQueue[T]) PipeTo(dst chan<- T) {
+	for {
+		t, ok, _ := q.DequeueWait()
+		if !ok {
+			return
+		}
+		dst <- t
+	}
+}
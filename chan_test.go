@@ -0,0 +1,267 @@
+package nqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// go test -run TestFeedFrom -v
+func TestFeedFrom(t *testing.T) {
+	t.Run("ChannelClosedFirst", func(t *testing.T) {
+		q := NewNQueueWithCap[int](2)
+		ch := make(chan int)
+		errc := make(chan error, 1)
+		go func() { errc <- FeedFrom[int](q, ch) }()
+
+		go func() {
+			for i := 0; i < 10; i++ {
+				ch <- i
+			}
+			close(ch)
+		}()
+
+		// 队列容量只有 2，FeedFrom 需要等待消费者腾出空位。
+		for i := 0; i < 10; i++ {
+			if v, ok, _ := q.DequeueWait(); !ok || v != i {
+				t.Fatalf("DequeueWait() = %d, %v, want %d, true", v, ok, i)
+			}
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("FeedFrom() = %v, want nil", err)
+		}
+		if q.IsClosed() {
+			t.Fatal("FeedFrom closed the queue")
+		}
+	})
+
+	t.Run("QueueClosedFirst", func(t *testing.T) {
+		q := NewNQueueWithCap[int](1)
+		ch := make(chan int)
+		errc := make(chan error, 1)
+		go func() { errc <- FeedFrom[int](q, ch) }()
+
+		ch <- 1
+		ch <- 2 // 队列已满，FeedFrom 阻塞在入队上。
+		q.Close()
+
+		select {
+		case err := <-errc:
+			if !errors.Is(err, ErrQueueClosed) {
+				t.Fatalf("FeedFrom() = %v, want ErrQueueClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("FeedFrom did not return after queue close")
+		}
+	})
+
+	t.Run("QueueClosedWhileIdle", func(t *testing.T) {
+		q := NewNQueue[int]()
+		errc := make(chan error, 1)
+		go func() { errc <- FeedFrom[int](q, make(chan int)) }()
+
+		q.Close()
+		select {
+		case err := <-errc:
+			if !errors.Is(err, ErrQueueClosed) {
+				t.Fatalf("FeedFrom() = %v, want ErrQueueClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("FeedFrom did not return after queue close")
+		}
+	})
+}
+
+// go test -run TestChan -v
+func TestChan(t *testing.T) {
+	for _, opts := range [][]ChanOption{
+		nil,
+		{WithChanBuffer(16)},
+		{WithChanBuffer(16), WithChanBatch(8)},
+	} {
+		q := NewNQueue[int]()
+		const n = 1000
+		go func() {
+			for i := 0; i < n; i++ {
+				q.Enqueue(i)
+			}
+			q.Close()
+		}()
+
+		i := 0
+		for v := range q.Chan(opts...) {
+			if v != i {
+				t.Fatalf("received %d, want %d", v, i)
+			}
+			i++
+		}
+		if i != n {
+			t.Fatalf("received %d items, want %d", i, n)
+		}
+	}
+}
+
+// go test -run none -bench BenchmarkChan -benchmem
+func BenchmarkChan(b *testing.B) {
+	for _, c := range []struct {
+		buffer, batch int
+	}{
+		{0, 1}, {1, 1}, {64, 1}, {64, 16}, {256, 64},
+	} {
+		b.Run(fmt.Sprintf("buffer=%d/batch=%d", c.buffer, c.batch), func(b *testing.B) {
+			q := NewNQueue[int]()
+			go func() {
+				for i := 0; i < b.N; i++ {
+					q.Enqueue(i)
+				}
+				q.Close()
+			}()
+			for range q.Chan(WithChanBuffer(c.buffer), WithChanBatch(c.batch)) {
+			}
+		})
+	}
+}
+
+// go test -run TestPipeTo -v
+func TestPipeTo(t *testing.T) {
+	q := NewNQueue[int]()
+	dst := make(chan int)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.PipeTo(dst)
+	}()
+
+	const n = 1000
+	go func() {
+		for i := 0; i < n; i++ {
+			q.Enqueue(i)
+		}
+		q.Close()
+	}()
+
+	for i := 0; i < n; i++ {
+		if v := <-dst; v != i {
+			t.Fatalf("received %d, want %d", v, i)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("PipeTo did not return after close and drain")
+	}
+
+	// dst 仍归调用方所有，没有被关闭。
+	select {
+	case v, ok := <-dst:
+		t.Fatalf("dst received %d, %v after PipeTo returned", v, ok)
+	default:
+	}
+}
+
+// go test -run TestToChan -v
+func TestToChan(t *testing.T) {
+	q := NewNQueue[int]()
+	for i := 0; i < 3; i++ {
+		q.Enqueue(i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := q.ToChan(ctx)
+	if v := <-ch; v != 0 {
+		t.Fatalf("received %d, want 0", v)
+	}
+
+	// ctx 结束后通道被关闭，没能发送出去的元素放回队列，队列保持打开。
+	// 取消时转发 goroutine 可能恰好完成了一次发送，因此收到的和剩余的元素合起来应当没有遗漏。
+	cancel()
+	var got []int
+	for v := range ch {
+		got = append(got, v)
+	}
+	if got = append(got, q.Drain()...); !slices.Equal(got, []int{1, 2}) || q.IsClosed() {
+		t.Fatalf("after cancel: got %v, IsClosed() = %v, want [1 2], false", got, q.IsClosed())
+	}
+	if s := q.Stats(); s.Count != 0 || s.Enqueued-s.Dequeued != 0 {
+		t.Fatalf("Stats() after cancel = %+v", s)
+	}
+}
+
+// go test -run TestToChanHeld -v
+func TestToChanHeld(t *testing.T) {
+	q := NewNQueue[int]()
+	q.EnqueueTagged("p1", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := q.ToChan(ctx)
+	for q.InFlight() != 1 {
+		runtime.Gosched()
+	}
+
+	// 等待发送的元素计入 InFlight：关闭后队列不会在元素放回之前进入终止状态。
+	q.Close()
+	if q.IsDrained() {
+		t.Fatal("IsDrained() = true while ToChan holds an item")
+	}
+	cancel()
+	for q.InFlight() != 0 {
+		runtime.Gosched() // 不读取通道，转发 goroutine 只能因 ctx 结束而放回元素。
+	}
+	for range ch {
+	}
+	if q.IsDrained() || q.Count() != 1 || q.InFlight() != 0 {
+		t.Fatalf("after cancel: IsDrained() = %v, Count() = %d, InFlight() = %d, want false, 1, 0", q.IsDrained(), q.Count(), q.InFlight())
+	}
+	// 放回的是原来的节点，标签保持不变。
+	if v, tag, ok, _ := q.DequeueTagged(); !ok || v != 1 || tag != "p1" || !q.IsDrained() {
+		t.Fatalf("DequeueTagged() = %d, %q, %v, IsDrained() = %v, want 1, p1, true, true", v, tag, ok, q.IsDrained())
+	}
+}
+
+// go test -run TestFromChan -v
+func TestFromChan(t *testing.T) {
+	q := NewNQueue[int]()
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	if err := q.FromChan(ch); err != nil || q.Count() != 2 {
+		t.Fatalf("FromChan() = %v, Count() = %d, want nil, 2", err, q.Count())
+	}
+}
+
+// go test -run TestAll -v
+func TestAll(t *testing.T) {
+	q := NewNQueue[int]()
+	go func() {
+		for i := 0; i < 100; i++ {
+			q.Enqueue(i)
+		}
+		q.Close()
+	}()
+
+	// 队列关闭且取空后循环结束。
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+	}
+	if len(got) != 100 || !slices.IsSorted(got) {
+		t.Fatalf("All() yielded %d items, sorted = %v", len(got), slices.IsSorted(got))
+	}
+
+	// 提前退出时其余元素留在队列中。
+	q = NewNQueue[int]()
+	q.EnqueueBatch([]int{1, 2, 3})
+	for v := range q.All() {
+		if v == 2 {
+			break
+		}
+	}
+	if got := q.Drain(); !slices.Equal(got, []int{3}) {
+		t.Fatalf("after break: Drain() = %v, want [3]", got)
+	}
+}
@@ -0,0 +1,116 @@
+package nqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrHandlerPanic 表示 Consume 的处理函数发生了 panic，WithOnError 收到的错误可以用 errors.Is 判断。
+var ErrHandlerPanic = errors.New("queue handler panicked")
+
+// consumerConfig 是 Consume 的配置。
+type consumerConfig struct {
+	ctx         context.Context // 结束时停止消费的 context。
+	concurrency int             // 并发处理元素的 worker 数量。
+	retries     int             // 处理失败后的最大重试次数。
+	backoff     time.Duration   // 第一次重试前的等待时间，之后每次翻倍。
+	onError     func(error)     // 重试用尽后仍然失败时调用的回调。
+}
+
+// ConsumerOption 是 Consume 的可选配置项。
+type ConsumerOption func(c *consumerConfig)
+
+// WithConcurrency 选项设置 Consume 并发处理元素的 worker 数量，默认为 1。
+func WithConcurrency(n int) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.concurrency = max(n, 1)
+	}
+}
+
+// WithRetry 选项让 Consume 在处理函数返回错误或 panic 时最多重试 n 次，第 i 次重试前等待 backoff * 2^(i-1)。
+// 重试在同一个 worker 中进行，不会把元素放回队列，因此其他元素的处理不受影响。默认不重试。
+func WithRetry(n int, backoff time.Duration) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.retries, c.backoff = max(n, 0), max(backoff, 0)
+	}
+}
+
+// WithOnError 选项设置一个回调，元素在重试用尽后仍然处理失败时以最后一次的错误调用，可能被多个 worker 并发调用。
+// 处理函数 panic 时错误包装了 ErrHandlerPanic。默认忽略失败的元素。
+func WithOnError(fn func(err error)) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.onError = fn
+	}
+}
+
+// WithConsumerContext 选项让 Consume 在 ctx 结束时停止：worker 不再出队新的元素，正在等待重试的元素不再重试，
+// 正在处理的元素仍会处理完成。队列本身不会被关闭。
+func WithConsumerContext(ctx context.Context) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.ctx = ctx
+	}
+}
+
+// Consume 函数启动一组 worker 并发地从 q 中出队元素并交给 fn 处理，阻塞直到所有 worker 退出，代替手工协调多个 DequeueFunc。
+// fn 返回错误或 panic 时按 WithRetry 重试，panic 会被恢复，不会使 worker 退出。
+//
+// q 被关闭后 worker 继续取出剩余的元素，队列取空且所有正在处理的元素都完成后 Consume 返回 nil，
+// 因此关闭队列即可优雅地停止消费而不丢失元素。设置了 WithConsumerContext 且 ctx 先结束时返回 ctx.Err()，
+// 此时队列中可能仍有元素。
+func Consume[T any](q Queue[T], fn func(T) error, opts ...ConsumerOption) error {
+	c := consumerConfig{ctx: context.Background(), concurrency: 1}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(c.concurrency)
+	for range c.concurrency {
+		go func() {
+			defer wg.Done()
+			for {
+				t, ok, _, err := q.DequeueContext(c.ctx)
+				if err != nil || !ok {
+					return // ctx 已结束，或队列已关闭且为空。
+				}
+				if err = consumeItem(&c, fn, t); err != nil && c.onError != nil {
+					c.onError(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return c.ctx.Err()
+}
+
+// consumeItem 函数处理一个元素，失败时按配置等待并重试，返回最后一次的错误。ctx 结束时不再重试。
+func consumeItem[T any](c *consumerConfig, fn func(T) error, t T) (err error) {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		if err = callHandler(fn, t); err == nil || attempt == c.retries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-c.ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// callHandler 函数调用 fn 处理 t，把 fn 中的 panic 转换为包装了 ErrHandlerPanic 的错误。
+func callHandler[T any](fn func(T) error, t T) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+		}
+	}()
+	return fn(t)
+}
@@ -0,0 +1,96 @@
+package nqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -run TestConsume -v
+func TestConsume(t *testing.T) {
+	const n = 1000
+	q := NewNQueue[int]()
+	for i := 0; i < n; i++ {
+		q.Enqueue(i)
+	}
+
+	// 多个 worker 并发处理；关闭后剩余元素仍会被处理完，Consume 才返回。
+	var active, peak, sum atomic.Int64
+	done := make(chan error)
+	go func() {
+		done <- Consume(q, func(v int) error {
+			if c := active.Add(1); c > peak.Load() {
+				peak.Store(c)
+			}
+			time.Sleep(10 * time.Microsecond)
+			sum.Add(int64(v))
+			active.Add(-1)
+			return nil
+		}, WithConcurrency(4))
+	}()
+	q.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Consume() = %v, want nil", err)
+	}
+	if sum.Load() != n*(n-1)/2 || peak.Load() > 4 {
+		t.Fatalf("sum = %d, peak = %d, want %d, <= 4", sum.Load(), peak.Load(), n*(n-1)/2)
+	}
+}
+
+// go test -run TestConsumeRetry -v
+func TestConsumeRetry(t *testing.T) {
+	q := NewNQueue[int]()
+	q.Enqueue(1) // 前两次失败，第三次成功。
+	q.Enqueue(2) // 每次都 panic，重试用尽后报告错误。
+	q.Close()
+
+	var mu sync.Mutex
+	attempts := make(map[int]int)
+	var errs []error
+	start := time.Now()
+	err := Consume(q, func(v int) error {
+		mu.Lock()
+		attempts[v]++
+		a := attempts[v]
+		mu.Unlock()
+		if v == 2 {
+			panic("boom")
+		}
+		if a < 3 {
+			return errors.New("retry")
+		}
+		return nil
+	}, WithRetry(2, 10*time.Millisecond), WithOnError(func(err error) { errs = append(errs, err) }))
+
+	if err != nil || attempts[1] != 3 || attempts[2] != 3 {
+		t.Fatalf("Consume() = %v, attempts = %v, want nil, 3 each", err, attempts)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrHandlerPanic) {
+		t.Fatalf("errors = %v, want one ErrHandlerPanic", errs)
+	}
+	// 每个元素等待 10ms + 20ms 两次退避。
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatalf("Consume() returned after %v, want >= 60ms of backoff", elapsed)
+	}
+}
+
+// go test -run TestConsumeContext -v
+func TestConsumeContext(t *testing.T) {
+	q := NewNQueue[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// ctx 结束时 Consume 返回，正在处理的元素完成处理，队列保持打开。
+	var handled atomic.Int64
+	q.Enqueue(1)
+	err := Consume(q, func(int) error {
+		handled.Add(1)
+		cancel()
+		return errors.New("fail")
+	}, WithConcurrency(2), WithRetry(5, time.Hour), WithConsumerContext(ctx))
+	if !errors.Is(err, context.Canceled) || handled.Load() != 1 || q.IsClosed() {
+		t.Fatalf("Consume() = %v, handled = %d, IsClosed() = %v", err, handled.Load(), q.IsClosed())
+	}
+}
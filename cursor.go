@@ -0,0 +1,89 @@
+package nqueue
+
+import "context"
+
+// cursorBatch 是游标每次从队列中摘取的最大节点数。
+const cursorBatch = 128
+
+// QueueCursor 是单消费者的快速出队游标，通过 NQueue.Cursor 创建。
+// 游标每次加锁时从队列头部摘取一批节点放入本地缓冲区，之后的 Next 调用无需加锁，
+// 从而把每次出队的加锁和唤醒开销分摊到整批元素上。
+//
+// QueueCursor 不是并发安全的，只能在一个 goroutine 中使用；
+// 多个消费者竞争出队时请使用 DequeueWait。
+// 已摘入缓冲区的元素不再计入队列的 Count()，而是与 DequeueAck 取出的元素一样计入 InFlight()，
+// 直到整批元素都经 Next 返回或被 Release 归还，因此 WaitDrain 和 Drained 会等待缓冲区处理完。
+// 不再使用游标时应调用 Release 归还尚未返回的元素。
+type QueueCursor[T any] struct {
+	q       *NQueue[T]
+	head    *node[T] // 本地缓冲区的第一个节点。
+	held    int64    // 最近一次摘取的节点数量，在整批返回或归还之前计入队列的 InFlight()。
+	isClose bool     // 最近一次摘取时队列是否已关闭。
+}
+
+// Cursor 方法创建一个绑定到队列的出队游标，参见 QueueCursor。
+func (q *NQueue[T]) Cursor() *QueueCursor[T] {
+	return &QueueCursor[T]{q: q}
+}
+
+// Next 方法返回下一个元素，本地缓冲区为空时阻塞等待，直到队列中有元素或队列关闭。
+// 返回值的含义与 DequeueWait 相同。
+func (c *QueueCursor[T]) Next() (t T, ok bool, isClose bool) {
+	if c.head == nil && !c.fill() {
+		return c.q.zeroValue, false, true
+	}
+
+	n := c.head
+	c.head = n.next
+	t = n.value
+	c.q.recycle(n)
+	if c.head == nil {
+		c.settle() // 每批只加锁一次，把整批元素从 InFlight() 中减去。
+	}
+	return t, true, c.isClose
+}
+
+// fill 方法阻塞等待并从队列中摘取一批节点；队列关闭且为空时返回 false。
+func (c *QueueCursor[T]) fill() bool {
+	q := c.q
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	q.waitWithContext(context.Background(), q.recvCond, q.canDequeue)
+	c.isClose = !q.status
+	reserved := min(cursorBatch, q.count.Load())
+	q.inflight += reserved // 先计入未确认数量，避免 popChain 误判队列已被取空。
+	c.head, c.held = q.popChain(cursorBatch)
+	q.inflight += c.held - reserved
+	return c.head != nil
+}
+
+// settle 方法在缓冲区中的元素全部返回后把它们从 InFlight() 中减去，队列可能因此进入终止状态。
+func (c *QueueCursor[T]) settle() {
+	q := c.q
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	q.inflight -= c.held
+	c.held = 0
+	if q.isEmpty() {
+		q.emptied()
+	}
+}
+
+// Release 方法把本地缓冲区中尚未返回的元素按原顺序放回队列头部。
+func (c *QueueCursor[T]) Release() {
+	if c.head == nil {
+		return
+	}
+
+	q := c.q
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	q.inflight -= c.held
+	c.held = 0
+	q.pushFrontChain(c.head)
+	c.head = nil
+}
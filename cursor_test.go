@@ -0,0 +1,110 @@
+package nqueue
+
+import (
+	"testing"
+)
+
+// go test -run TestCursor -v
+func TestCursor(t *testing.T) {
+	q := NewNQueue[int]()
+	const total = cursorBatch*2 + 7
+	for i := 0; i < total; i++ {
+		q.Enqueue(i)
+	}
+
+	c := q.Cursor()
+	for i := 0; i < 10; i++ {
+		if v, ok, _ := c.Next(); !ok || v != i {
+			t.Fatalf("Next() = %d, %v, want %d, true", v, ok, i)
+		}
+	}
+
+	// 归还缓冲区后，剩余元素按原顺序留在队列中。
+	c.Release()
+	if n := q.Count(); n != total-10 {
+		t.Fatalf("Count() after Release = %d, want %d", n, total-10)
+	}
+	if v, ok, _ := q.Dequeue(); !ok || v != 10 {
+		t.Fatalf("Dequeue() = %d, %v, want 10, true", v, ok)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 11; ; i++ {
+			v, ok, isClose := c.Next()
+			if !ok {
+				if !isClose || i != total+1 {
+					t.Errorf("Next() stopped at %d, isClose %v, want %d, true", i, isClose, total+1)
+				}
+				return
+			}
+			if v != i {
+				t.Errorf("Next() = %d, want %d", v, i)
+				return
+			}
+		}
+	}()
+
+	q.Enqueue(total)
+	q.Close()
+	<-done
+}
+
+// go test -run none -bench BenchmarkConsume -benchmem
+func BenchmarkConsume(b *testing.B) {
+	fill := func(b *testing.B) *NQueue[int] {
+		q := NewNQueue[int]()
+		for i := 0; i < b.N; i++ {
+			q.Enqueue(i)
+		}
+		q.Close()
+		b.ResetTimer()
+		return q
+	}
+
+	b.Run("DequeueWait", func(b *testing.B) {
+		q := fill(b)
+		for {
+			if _, ok, _ := q.DequeueWait(); !ok {
+				return
+			}
+		}
+	})
+
+	b.Run("Cursor", func(b *testing.B) {
+		c := fill(b).Cursor()
+		for {
+			if _, ok, _ := c.Next(); !ok {
+				return
+			}
+		}
+	})
+}
+
+// go test -run TestCursorDrained -v
+func TestCursorDrained(t *testing.T) {
+	q := NewNQueue[int]()
+	q.Enqueue(1)
+	q.Enqueue(2)
+
+	// 缓冲区中尚未返回的元素计入 InFlight，关闭后队列不会在它们归还之前进入终止状态。
+	c := q.Cursor()
+	c.Next()
+	q.Close()
+	if q.IsDrained() || q.InFlight() != 2 {
+		t.Fatalf("IsDrained() = %v, InFlight() = %d with buffered items, want false, 2", q.IsDrained(), q.InFlight())
+	}
+	c.Release()
+	if q.IsDrained() || q.Count() != 1 || q.InFlight() != 0 {
+		t.Fatalf("after Release: IsDrained() = %v, Count() = %d, InFlight() = %d, want false, 1, 0", q.IsDrained(), q.Count(), q.InFlight())
+	}
+
+	// 整批元素都返回后队列进入终止状态。
+	if v, ok, _ := c.Next(); !ok || v != 2 || !q.IsDrained() {
+		t.Fatalf("Next() = %d, %v, IsDrained() = %v, want 2, true, true", v, ok, q.IsDrained())
+	}
+	if _, ok, isClose := c.Next(); ok || !isClose {
+		t.Fatalf("Next() on drained queue = %v, %v, want false, true", ok, isClose)
+	}
+}
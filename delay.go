@@ -0,0 +1,127 @@
+package nqueue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// delayItem 是一个等待到期的节点。
+type delayItem[T any] struct {
+	n        *node[T]
+	at       int64  // 到期时间（Unix 纳秒）。
+	seq      uint64 // 加入顺序，用于保证到期时间相同的元素先进先出。
+	requeued bool   // 是否是按 RequeueDelay 延迟放回的未确认元素，到期前仍计入 InFlight()。
+}
+
+// delayHeap 是按到期时间排序的最小堆，实现了 heap.Interface。
+type delayHeap[T any] struct {
+	items []delayItem[T]
+	seq   uint64
+}
+
+func (h *delayHeap[T]) Len() int { return len(h.items) }
+
+func (h *delayHeap[T]) Less(i, j int) bool {
+	if h.items[i].at != h.items[j].at {
+		return h.items[i].at < h.items[j].at
+	}
+	return h.items[i].seq < h.items[j].seq
+}
+
+func (h *delayHeap[T]) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *delayHeap[T]) Push(x any) { h.items = append(h.items, x.(delayItem[T])) }
+
+func (h *delayHeap[T]) Pop() any {
+	last := len(h.items) - 1
+	it := h.items[last]
+	h.items[last] = delayItem[T]{}
+	h.items = h.items[:last]
+	return it
+}
+
+// EnqueueAfter 方法把值 v 延迟 d 之后放入队列尾部，在此之前 v 不可出队，也不计入 Count()，参见 EnqueueAt。
+func (q *NQueue[T]) EnqueueAfter(v T, d time.Duration) error {
+	return q.EnqueueAt(v, time.Now().Add(d))
+}
+
+// EnqueueAt 方法在时间 t 把值 v 放入队列尾部，适合重试退避和定时任务。等待期间 v 不可出队，也不计入 Count()，
+// 而是计入 Delayed()；WaitDrain 会等待它到期并被取走。多个元素按到期时间依次可见，到期时间相同的按调用顺序。
+// 到期的元素不受容量上限的限制，因为它们在调用时没有等待空位；t 已经过去时与 Enqueue 相同。
+// 队列已关闭时返回 ErrQueueClosed；关闭时尚未到期的元素会立即放入队列，保证不会丢失。
+//
+// 所有延迟的元素共用一个定时器，到期由独立的 goroutine 完成，不受 nqueue_deterministic 构建标签下确定性调度器的控制。
+// Transfer 和 Swap 不会移动尚未到期的元素。
+func (q *NQueue[T]) EnqueueAt(v T, t time.Time) error {
+	if !t.After(time.Now()) {
+		return q.Enqueue(v)
+	}
+
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	if !q.status {
+		return ErrQueueClosed
+	}
+	q.delay(q.newNode(v), t.UnixNano(), false)
+	return nil
+}
+
+// Delayed 方法返回通过 EnqueueAfter、EnqueueAt 或按 RequeueDelay 放回、尚未到期的元素数量。
+func (q *NQueue[T]) Delayed() int {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	return q.delays.Len()
+}
+
+// delay 方法把节点 n 加入延迟堆，在时间 at 放入队列尾部，并在它成为最早到期的元素时调整定时器。
+// requeued 表示 n 是延迟放回的未确认元素，调用方已把它计入 inflight。调用方需持有 recvLock。
+func (q *NQueue[T]) delay(n *node[T], at int64, requeued bool) {
+	q.delays.seq++
+	heap.Push(&q.delays, delayItem[T]{n: n, at: at, seq: q.delays.seq, requeued: requeued})
+	if q.delays.items[0].n != n {
+		return // 更早到期的元素已经设置了定时器。
+	}
+
+	d := time.Duration(at - time.Now().UnixNano())
+	if q.timer == nil {
+		q.timer = time.AfterFunc(d, q.fire)
+	} else {
+		q.timer.Reset(d)
+	}
+}
+
+// fire 方法在定时器到期时调用，把所有已到期的元素放入队列，并把定时器设置为下一个元素的到期时间。
+func (q *NQueue[T]) fire() {
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	q.release(time.Now().UnixNano())
+	if q.delays.Len() > 0 {
+		q.timer.Reset(time.Duration(q.delays.items[0].at - time.Now().UnixNano()))
+	}
+}
+
+// release 方法按到期顺序把到期时间不晚于 now 的元素成批放入队列尾部。调用方需持有 recvLock。
+func (q *NQueue[T]) release(now int64) {
+	var first, last *node[T]
+	for q.delays.Len() > 0 && q.delays.items[0].at <= now {
+		it := heap.Pop(&q.delays).(delayItem[T])
+		if it.requeued {
+			q.inflight--
+		}
+		if first == nil {
+			first = it.n
+		} else {
+			last.next = it.n
+		}
+		last = it.n
+	}
+	if first == nil {
+		return
+	}
+
+	q.restamp(first) // 元素从可见时开始计算等待时间。
+	q.pushBack(first)
+}
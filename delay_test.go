@@ -0,0 +1,61 @@
+package nqueue
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// go test -run TestEnqueueAfter -v
+func TestEnqueueAfter(t *testing.T) {
+	q := NewNQueue[int]()
+	start := time.Now()
+	q.EnqueueAfter(3, 90*time.Millisecond)
+	q.EnqueueAfter(1, 30*time.Millisecond)
+	q.EnqueueAt(2, start.Add(60*time.Millisecond))
+	q.EnqueueAt(4, start.Add(60*time.Millisecond)) // 到期时间相同的元素按调用顺序可见。
+
+	// 尚未到期的元素不可出队，也不计入 Count()。
+	if _, ok, _ := q.Dequeue(); ok || q.Count() != 0 || q.Delayed() != 4 {
+		t.Fatalf("before deadline: ok = %v, Count() = %d, Delayed() = %d, want false, 0, 4", ok, q.Count(), q.Delayed())
+	}
+
+	// 已经过去的时间与 Enqueue 相同，立即可见。
+	q.EnqueueAfter(0, -time.Second)
+
+	var got []int
+	for range 5 {
+		v, _, _ := q.DequeueWait()
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{0, 1, 2, 4, 3}) || time.Since(start) < 90*time.Millisecond {
+		t.Fatalf("got %v after %v, want [0 1 2 4 3] after 90ms", got, time.Since(start))
+	}
+	if q.Delayed() != 0 {
+		t.Fatalf("Delayed() = %d, want 0", q.Delayed())
+	}
+}
+
+// go test -run TestEnqueueAfterClose -v
+func TestEnqueueAfterClose(t *testing.T) {
+	q := NewNQueue[int]()
+	q.EnqueueAfter(2, time.Hour)
+	q.EnqueueAfter(1, time.Minute)
+	if q.IsDrained() || q.WaitDrain(nonBlocking) == nil {
+		t.Fatal("queue with delayed items reported as drained")
+	}
+
+	// 关闭时尚未到期的元素按到期顺序立即放入队列，关闭后的延迟入队被拒绝。
+	q.Close()
+	if err := q.EnqueueAfter(3, time.Millisecond); err != ErrQueueClosed {
+		t.Fatalf("EnqueueAfter() after Close = %v, want %v", err, ErrQueueClosed)
+	}
+	var got []int
+	q.DequeueFunc(func(v int, isClose bool) bool {
+		got = append(got, v)
+		return true
+	})
+	if !slices.Equal(got, []int{1, 2}) || !q.IsDrained() {
+		t.Fatalf("got %v, IsDrained() = %v, want [1 2], true", got, q.IsDrained())
+	}
+}
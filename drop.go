@@ -0,0 +1,94 @@
+package nqueue
+
+// DropReason 表示元素被队列丢弃的原因，由 WithOnDrop 设置的回调接收。
+type DropReason int
+
+const (
+	// DropOverflowNewest 表示有界队列已满，按 OverflowDropNewest 丢弃了正在入队的元素。
+	DropOverflowNewest DropReason = iota + 1
+	// DropOverflowOldest 表示有界队列已满，按 OverflowDropOldest 丢弃了头部最早的元素。
+	DropOverflowOldest
+	// DropSpillFailed 表示开启 WithSpill 时溢出到磁盘的元素无法读回，回调收到的值为零值；
+	// 按 RequeueTail 或 RequeueDelay 放回的元素写入磁盘失败时也以此报告，回调收到的是元素本身。
+	DropSpillFailed
+	// DropDeadLetterFailed 表示被丢弃的元素无法放入 WithDeadLetter 设置的死信队列（已满或已关闭），元素最终丢失。
+	DropDeadLetterFailed
+	// DropDiscarded 表示元素被 CloseAndDiscard 或 Reset 主动丢弃，不会转发到死信队列。
+	DropDiscarded
+	// DropMaxAttempts 表示元素的投递次数已达到 WithMaxAttempts 设置的上限，确认回调再次要求重新投递时被放弃。
+	DropMaxAttempts
+)
+
+// String 方法返回丢弃原因的名称。
+func (r DropReason) String() string {
+	switch r {
+	case DropOverflowNewest:
+		return "overflow-newest"
+	case DropOverflowOldest:
+		return "overflow-oldest"
+	case DropSpillFailed:
+		return "spill-failed"
+	case DropDeadLetterFailed:
+		return "dead-letter-failed"
+	case DropDiscarded:
+		return "discarded"
+	case DropMaxAttempts:
+		return "max-attempts"
+	default:
+		return "unknown"
+	}
+}
+
+// OverflowPolicy 决定有界队列已满时入队的行为，由 WithOverflow 设置。
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 表示入队阻塞等待空位，这是默认行为。
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest 表示丢弃正在入队的元素，队列中已有的元素不受影响。
+	OverflowDropNewest
+	// OverflowDropOldest 表示丢弃头部最早的元素，为新元素腾出空位。
+	OverflowDropOldest
+)
+
+// overflow 方法在有界队列已满且设置了丢弃策略时为值 v 腾出空位或丢弃它，返回 v 是否已被丢弃。
+// 队列未满、已关闭或没有设置丢弃策略时什么也不做。调用方需持有 recvLock。
+func (q *NQueue[T]) overflow(v T) (dropped bool) {
+	if q.onFull == OverflowBlock || q.canEnqueue() {
+		return false
+	}
+
+	if q.onFull == OverflowDropNewest {
+		q.drop(v, DropOverflowNewest)
+		return true
+	}
+
+	for !q.canEnqueue() && q.head != nil {
+		n := q.popNode()
+		q.drop(n.value, DropOverflowOldest)
+		q.recycle(n)
+	}
+	return false
+}
+
+// drop 方法记录一个被丢弃的元素，调用 WithOnDrop 设置的回调，并把元素转发到 WithDeadLetter 设置的死信队列。
+// 转发不会阻塞：死信队列已满或已关闭时元素丢失，以 DropDeadLetterFailed 再报告一次。调用方需持有 recvLock。
+func (q *NQueue[T]) drop(v T, reason DropReason) {
+	q.dropped++
+	if q.onDrop != nil {
+		q.onDrop(v, reason)
+	}
+
+	if q.dlq == nil || reason == DropSpillFailed || reason == DropDiscarded {
+		return // 无法读回的元素只有零值，主动丢弃的元素是调用方有意放弃的，都不转发。
+	}
+	enterNested() // 持有 recvLock 时调用死信队列，不能在这里让出确定性调度的执行权。
+	failed := q.dlq == Queue[T](q) || q.dlq.EnqueueContext(nonBlocking, v) != nil
+	exitNested()
+	if failed {
+		q.dlqFailed++
+		if q.onDrop != nil {
+			q.onDrop(v, DropDeadLetterFailed)
+		}
+	}
+}
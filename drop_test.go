@@ -0,0 +1,155 @@
+package nqueue
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// dropRecord 是 WithOnDrop 回调收到的一次丢弃。
+type dropRecord struct {
+	v      int
+	reason DropReason
+}
+
+// go test -run TestWithOnDrop -v
+func TestWithOnDrop(t *testing.T) {
+	var drops []dropRecord
+	onDrop := WithOnDrop(func(v int, reason DropReason) { drops = append(drops, dropRecord{v, reason}) })
+
+	cases := []struct {
+		name   string
+		policy OverflowPolicy
+		want   []int        // 队列中剩余的元素。
+		drops  []dropRecord // 期望的丢弃记录。
+	}{
+		{"newest", OverflowDropNewest, []int{1, 2, 3}, []dropRecord{{4, DropOverflowNewest}, {5, DropOverflowNewest}}},
+		{"oldest", OverflowDropOldest, []int{3, 4, 5}, []dropRecord{{1, DropOverflowOldest}, {2, DropOverflowOldest}}},
+	}
+	for _, c := range cases {
+		drops = nil
+		q := NewNQueueWithCap(3, WithOverflow[int](c.policy), onDrop)
+		for i := 1; i <= 5; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Fatalf("%s: Enqueue(%d) = %v", c.name, i, err)
+			}
+		}
+
+		if got := q.Snapshot(); !slices.Equal(got, c.want) {
+			t.Fatalf("%s: Snapshot() = %v, want %v", c.name, got, c.want)
+		}
+		if !slices.Equal(drops, c.drops) {
+			t.Fatalf("%s: drops = %v, want %v", c.name, drops, c.drops)
+		}
+		if s := q.Stats(); s.Dropped != 2 || s.Count != 3 {
+			t.Fatalf("%s: Stats() = %+v, want Dropped 2, Count 3", c.name, s)
+		}
+	}
+
+	// 溢出到磁盘的元素无法读回时以 DropSpillFailed 报告。
+	drops = nil
+	decode := func(b []byte) (int, error) {
+		if string(b) == "2" {
+			return 0, strconv.ErrSyntax
+		}
+		return decodeInt(b)
+	}
+	q := NewNQueue(WithSpill(t.TempDir(), 1, encodeInt, decode), onDrop)
+	q.Enqueue(1)
+	q.Enqueue(2)
+	q.Enqueue(3)
+	q.Close()
+	q.DequeueFunc(func(int, bool) bool { return true })
+	if want := []dropRecord{{0, DropSpillFailed}}; !slices.Equal(drops, want) {
+		t.Fatalf("spill drops = %v, want %v", drops, want)
+	}
+
+	// 默认的 OverflowBlock 不会丢弃元素，队列已满时入队等待空位。
+	drops = nil
+	q = NewNQueueWithCap(1, onDrop)
+	q.Enqueue(1)
+	if err := q.EnqueueContext(nonBlocking, 2); err == nil || len(drops) != 0 {
+		t.Fatalf("EnqueueContext() on full blocking queue = %v, drops = %v", err, drops)
+	}
+}
+
+// go test -run TestWithDeadLetter -v
+func TestWithDeadLetter(t *testing.T) {
+	dlq := NewNQueueWithCap[int](3)
+	var lost []int
+	q := NewNQueueWithCap(2,
+		WithOverflow[int](OverflowDropOldest),
+		WithDeadLetter[int](dlq),
+		WithOnDrop(func(v int, reason DropReason) {
+			if reason == DropDeadLetterFailed {
+				lost = append(lost, v)
+			}
+		}),
+	)
+
+	// 被挤出的元素按丢弃顺序进入死信队列。
+	for i := 1; i <= 5; i++ {
+		q.Enqueue(i)
+	}
+	if got := dlq.Snapshot(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("dlq = %v, want [1 2 3]", got)
+	}
+
+	// 死信队列已满时不会阻塞，元素以 DropDeadLetterFailed 报告。
+	q.Enqueue(6)
+	if got := dlq.Snapshot(); !slices.Equal(got, []int{1, 2, 3}) || !slices.Equal(lost, []int{4}) {
+		t.Fatalf("dlq = %v, lost = %v, want [1 2 3], [4]", got, lost)
+	}
+
+	// 死信队列关闭后同样按失败处理。
+	dlq.Dequeue()
+	dlq.Close()
+	q.Enqueue(7)
+	if s := q.Stats(); s.Dropped != 5 || s.DLQFailed != 2 || !slices.Equal(lost, []int{4, 5}) {
+		t.Fatalf("Stats() = %+v, lost = %v, want Dropped 5, DLQFailed 2, lost [4 5]", s, lost)
+	}
+	if got := q.Snapshot(); !slices.Equal(got, []int{6, 7}) {
+		t.Fatalf("Snapshot() = %v, want [6 7]", got)
+	}
+}
+
+// go test -run TestWithMaxAttempts -v
+func TestWithMaxAttempts(t *testing.T) {
+	dlq := NewNQueue[int]()
+	var drops []dropRecord
+	q := NewNQueue(
+		WithMaxAttempts[int](3),
+		WithDeadLetter[int](dlq),
+		WithOnDrop(func(v int, reason DropReason) { drops = append(drops, dropRecord{v, reason}) }),
+	)
+	q.Enqueue(1)
+	q.Enqueue(2)
+
+	// 元素 1 前两次投递失败后重新放回，第三次投递仍失败时转入死信队列。
+	for want := 1; want <= 3; want++ {
+		v, attempt, ack, _ := q.DequeueAckMeta()
+		if v != 1 || attempt != want {
+			t.Fatalf("DequeueAckMeta() = %d, attempt %d, want 1, attempt %d", v, attempt, want)
+		}
+		ack(true)
+	}
+	if got := dlq.Snapshot(); !slices.Equal(got, []int{1}) || !slices.Equal(drops, []dropRecord{{1, DropMaxAttempts}}) {
+		t.Fatalf("dlq = %v, drops = %v, want [1], [{1 max-attempts}]", got, drops)
+	}
+
+	// 批量确认时只有达到上限的元素被放弃，其余元素按原顺序放回。
+	q.Enqueue(3)
+	ts, ack, _ := q.DequeueBatchAck(2)
+	ack(0)
+	ts, ack, _ = q.DequeueBatchAck(2)
+	ack(0)
+	ts, ack, _ = q.DequeueBatchAck(2)
+	if !slices.Equal(ts, []int{2, 3}) {
+		t.Fatalf("DequeueBatchAck() = %v, want [2 3]", ts)
+	}
+	ack(0)
+	q.Close()
+	if got := dlq.Snapshot(); !slices.Equal(got, []int{1, 2, 3}) || !q.IsDrained() {
+		t.Fatalf("dlq = %v, IsDrained() = %v, want [1 2 3], true", got, q.IsDrained())
+	}
+}
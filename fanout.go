@@ -0,0 +1,56 @@
+package nqueue
+
+// FanOutPolicy 决定 FanOutBuffered 在某个输出队列已满时的行为。
+type FanOutPolicy int
+
+const (
+	// FanOutBlock 表示等待已满的输出队列腾出空位。一个慢消费者会拖慢所有输出队列。
+	FanOutBlock FanOutPolicy = iota
+	// FanOutDrop 表示丢弃要放入已满输出队列的元素，其他输出队列不受影响。
+	FanOutDrop
+)
+
+// FanOut 函数把 src 中的每个元素复制到 n 个无界的输出队列，让 n 个消费者都能收到完整的元素流（发布/订阅），
+// 而不是竞争同一批元素。内部启动一个转发 goroutine 从 src 出队，src 关闭且所有元素转发完成后关闭所有输出队列。
+//
+// 每个元素会以值的形式被复制 n 次，内存开销约为单个队列的 n 倍；T 为指针或引用类型时各输出共享同一个底层对象。
+// 输出队列不限容量，慢消费者不会阻塞其他输出，但它的队列会持续增长；需要限制内存时使用 FanOutBuffered。
+func FanOut[T any](src Queue[T], n int) []Queue[T] {
+	return fanOut(src, n, 0, FanOutBlock)
+}
+
+// FanOutBuffered 函数与 FanOut 相同，但每个输出队列最多缓冲 buffer 个元素，
+// 输出队列已满时的行为由 policy 决定，参见 FanOutPolicy。
+func FanOutBuffered[T any](src Queue[T], n, buffer int, policy FanOutPolicy) []Queue[T] {
+	return fanOut(src, n, buffer, policy)
+}
+
+func fanOut[T any](src Queue[T], n, buffer int, policy FanOutPolicy) []Queue[T] {
+	outs := make([]Queue[T], n)
+	for i := range outs {
+		outs[i] = NewNQueueWithCap[T](buffer)
+	}
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				out.Close()
+			}
+		}()
+
+		for {
+			t, ok, _ := src.DequeueWait()
+			if !ok {
+				return // src 已关闭且为空。
+			}
+			for _, out := range outs {
+				if policy == FanOutDrop {
+					out.EnqueueContext(nonBlocking, t) // 输出队列已满时丢弃。
+				} else {
+					out.Enqueue(t) // 输出队列被消费者关闭时返回错误，跳过即可。
+				}
+			}
+		}
+	}()
+	return outs
+}
@@ -0,0 +1,87 @@
+package nqueue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// go test -run TestFanOut -v
+func TestFanOut(t *testing.T) {
+	src := NewNQueue[int]()
+	outs := FanOut[int](src, 3)
+
+	const n = 1000
+	var wg sync.WaitGroup
+	wg.Add(len(outs))
+	for _, out := range outs {
+		go func(out Queue[int]) {
+			defer wg.Done()
+			i := 0
+			out.DequeueFunc(func(v int, isClose bool) bool {
+				if v != i {
+					t.Errorf("received %d, want %d", v, i)
+				}
+				i++
+				return true
+			})
+			if i != n {
+				t.Errorf("received %d items, want %d", i, n)
+			}
+		}(out)
+	}
+
+	for i := 0; i < n; i++ {
+		src.Enqueue(i)
+	}
+	src.Close()
+	wg.Wait()
+}
+
+// go test -run TestFanOutBuffered -v
+func TestFanOutBuffered(t *testing.T) {
+	t.Run("Drop", func(t *testing.T) {
+		src := NewNQueue[int]()
+		outs := FanOutBuffered[int](src, 2, 4, FanOutDrop)
+
+		// outs[1] 没有消费者，满了之后丢弃，outs[0] 仍然收到全部元素。
+		const n = 100
+		for i := 0; i < n; i++ {
+			src.Enqueue(i)
+			if v, ok, _ := outs[0].DequeueWait(); !ok || v != i {
+				t.Fatalf("fast output received %d, %v, want %d, true", v, ok, i)
+			}
+		}
+		src.Close()
+
+		if c := outs[1].Count(); c != 4 {
+			t.Fatalf("slow output buffered %d items, want 4", c)
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		src := NewNQueue[int]()
+		outs := FanOutBuffered[int](src, 2, 4, FanOutBlock)
+		for i := 0; i < 10; i++ {
+			src.Enqueue(i)
+		}
+
+		// outs[1] 没有被消费，转发阻塞，outs[0] 也最多只能收到 buffer+1 个元素。
+		time.Sleep(20 * time.Millisecond)
+		if c := outs[0].Count(); c > 4 {
+			t.Fatalf("fast output buffered %d items, want <= 4", c)
+		}
+		if c := src.Count(); c == 0 {
+			t.Fatal("forwarding did not block on the slow output")
+		}
+
+		// 慢消费者开始消费后，所有元素都能送达。
+		go outs[1].DequeueFunc(func(int, bool) bool { return true })
+		got := 0
+		src.Close()
+		outs[0].DequeueFunc(func(int, bool) bool { got++; return true })
+		if got != 10 {
+			t.Fatalf("fast output received %d items, want 10", got)
+		}
+	})
+}
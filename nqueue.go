@@ -1,42 +1,138 @@
 package nqueue
 
 import (
+	"context"
 	"errors"
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 自定义错误变量
 var (
 	ErrQueueClosed      = errors.New("queue is closed")
 	ErrQueueClosedEmpty = errors.New("queue is closed and empty")
+	ErrQueueInUse       = errors.New("queue is open or has unacknowledged items")
+	ErrQueueSpilled     = errors.New("queue has items spilled to disk")
 )
 
+// queueSeq 用于为每个队列分配唯一的编号，同时锁住两个队列时按编号顺序加锁以避免死锁。
+var queueSeq atomic.Uint64
+
 // NQueue 是一个泛型队列结构体，用于存储任意类型的数据。
 // 它使用链表实现，支持并发安全的入队和出队操作，并且提供了阻塞和非阻塞的出队方式。
+// 所有可选功能（时间戳、字节统计、回调等）都由 Option 开启，未开启时热路径上只有一次对零值字段的判断，
+// 入队和出队除了维护元素数量之外不做额外的原子操作。
+// NQueue 总是严格先进先出；可以接受近似先进先出以换取更低锁争用的场景使用 RelaxedQueue。
 type NQueue[T any] struct {
-	head      *node[T]     // 队列的头节点指针，指向队列的第一个元素。
-	tail      *node[T]     // 队列的尾节点指针，指向队列的最后一个元素。
-	status    bool         // 队列的状态，true 表示队列处于打开状态，false 表示队列已关闭。
-	count     int64        // 队列中元素的数量。
-	recvLock  sync.RWMutex // 读写锁，用于保证并发操作时的线程安全。
-	nodePool  sync.Pool    // 节点对象池，用于复用节点，减少内存分配和垃圾回收的开销。
-	zeroValue T            // 泛型类型的零值，用于在出队时重置节点的值。
-	recvCond  *sync.Cond   // 条件变量，用于在队列为空时阻塞出队操作，直到有新元素入队或队列关闭。
+	id        uint64              // 队列的唯一编号。
+	head      *node[T]            // 队列的头节点指针，指向队列的第一个元素。
+	tail      *node[T]            // 队列的尾节点指针，指向队列的最后一个元素。
+	status    bool                // 队列的状态，true 表示队列处于打开状态，false 表示队列已关闭。
+	count     atomic.Int64        // 队列中元素的数量，在 recvLock 保护下修改，读取时无需加锁。
+	inflight  int64               // 已通过 DequeueAck 或 DequeueBatchAck 取出但尚未确认的元素数量。
+	capacity  int64               // 队列的容量上限，0 表示不限制容量。
+	recvLock  sync.RWMutex        // 读写锁，用于保证并发操作时的线程安全。
+	nodePool  sync.Pool           // 节点对象池，用于复用节点，减少内存分配和垃圾回收的开销。
+	zeroValue T                   // 泛型类型的零值，用于在出队时重置节点的值。
+	recvCond  *sync.Cond          // 条件变量，用于在队列为空时阻塞出队操作，直到有新元素入队或队列关闭。
+	sendCond  *sync.Cond          // 条件变量，用于在队列已满时阻塞入队操作，直到有空位或队列关闭。
+	drainCond *sync.Cond          // 条件变量，用于等待队列被取空。
+	done      chan struct{}       // 队列关闭时被关闭的通道。
+	drained   chan struct{}       // 队列关闭且所有元素都已被取走并确认时被关闭的通道。
+	closeCtx  context.Context     // WithCloseOnContext 绑定的 context，结束时自动关闭队列。
+	onEmpty   func()              // WithOnEmpty 设置的回调，队列从非空变为空时异步调用。
+	lazyWake  bool                // WithLazyWakeup 开启后，没有消费者阻塞等待时入队不再发出唤醒。
+	parked    int                 // 正在 recvCond 上阻塞等待元素的消费者数量。
+	wakeups   uint64              // 入队时发出的唤醒次数。
+	sizeOf    func(T) int         // WithSizeOf 设置的元素大小计算函数。
+	bytes     atomic.Int64        // 待出队元素的总字节数，只有设置了 sizeOf 时才会维护。
+	stamped   bool                // WithTimestamps 开启后，入队时记录每个元素的入队时间。
+	stuckAge  time.Duration       // WithStuckWatchdog 设置的头部元素最长滞留时间。
+	onStuck   func(time.Duration) // 头部元素滞留超过 stuckAge 时调用的回调。
+	createdAt time.Time           // 队列的创建时间。
+	enqueued  uint64              // 累计入队的元素数量，在 recvLock 保护下修改。
+	dequeued  uint64              // 累计出队的元素数量，在 recvLock 保护下修改。
+	peak      int64               // 队列元素数量的历史最高值，在 recvLock 保护下修改。
+	softLimit int64               // WithSoftLimit 设置的软上限，0 表示不检测。
+	onExceed  func(cur int)       // 元素数量超过软上限时异步调用的回调。
+	exceeded  bool                // 元素数量是否已超过软上限且尚未回落到重新检测的阈值。
+	spill     *spill[T]           // WithSpill 设置的磁盘溢出区，超出内存上限的元素保存在这里。
+	onFull    OverflowPolicy      // WithOverflow 设置的有界队列已满时的入队策略。
+	onDrop    func(T, DropReason) // WithOnDrop 设置的回调，每个被丢弃的元素都会调用一次。
+	dropped   uint64              // 累计丢弃的元素数量，在 recvLock 保护下修改。
+	dlq       Queue[T]            // WithDeadLetter 设置的死信队列，被丢弃的元素会被转发到这里。
+	dlqFailed uint64              // 无法放入死信队列而最终丢失的元素数量，在 recvLock 保护下修改。
+	requeueAt RequeuePolicy       // WithRequeuePolicy 设置的未确认元素的放回位置。
+	maxTries  int32               // WithMaxAttempts 设置的最大投递次数，0 表示不限制。
+	delays    delayHeap[T]        // 尚未到期的元素，按到期时间排序。
+	timer     *time.Timer         // 最早到期的元素的定时器，第一次延迟入队时创建。
+	hooks     Hooks               // WithHooks 设置的观测回调。
+	waits     []time.Duration     // 开启 WithTimestamps 时最近出队元素的等待时间，用于计算 Stats 中的分位数。
+	waitNext  int                 // waits 中下一个要写入的位置。
 }
 
 // node 是队列中每个节点的结构体，包含一个泛型类型的值和指向下一个节点的指针。
 type node[T any] struct {
-	value T        // 节点存储的值。
-	next  *node[T] // 指向下一个节点的指针。
+	value T         // 节点存储的值。
+	next  *node[T]  // 指向下一个节点的指针。
+	meta  *nodeMeta // 节点的可选元数据，只有开启 WithTimestamps、使用 EnqueueTagged 或重新投递时才会分配。
+}
+
+// nodeMeta 是节点的可选元数据。不使用这些功能的队列每个节点只多一个 nil 指针，出队时也不需要清理。
+// 元数据随节点一起被对象池复用，回收时清零。
+type nodeMeta struct {
+	enqueuedAt int64  // 入队时间（Unix 纳秒），只有开启 WithTimestamps 时才会记录。
+	tag        string // EnqueueTagged 设置的生产者标签。
+	attempts   int32  // 元素通过确认回调被重新投递的次数。
+}
+
+// metadata 方法返回节点的元数据，还没有分配时先分配。
+func (n *node[T]) metadata() *nodeMeta {
+	if n.meta == nil {
+		n.meta = &nodeMeta{}
+	}
+	return n.meta
+}
+
+// enqueuedAt 方法返回节点的入队时间，没有记录时返回 0。
+func (n *node[T]) enqueuedAt() int64 {
+	if n.meta == nil {
+		return 0
+	}
+	return n.meta.enqueuedAt
+}
+
+// tag 方法返回节点的生产者标签，没有标签时返回空字符串。
+func (n *node[T]) tag() string {
+	if n.meta == nil {
+		return ""
+	}
+	return n.meta.tag
+}
+
+// attempts 方法返回节点被重新投递的次数。
+func (n *node[T]) attempts() int32 {
+	if n.meta == nil {
+		return 0
+	}
+	return n.meta.attempts
 }
 
 // 新建队列，返回一个空队列
 // NewNQueue 函数用于创建一个新的 NQueue 实例，初始化队列的状态、元素数量、条件变量和节点对象池。
-func NewNQueue[T any]() *NQueue[T] {
+// opts 为可选配置项，参见 Option。
+func NewNQueue[T any](opts ...Option[T]) *NQueue[T] {
 	q := &NQueue[T]{}
+	q.id = queueSeq.Add(1)
+	q.createdAt = time.Now()
 	q.status = true                        // 初始化队列状态为打开。
-	q.count = 0                            // 初始化队列元素数量为 0。
 	q.recvCond = sync.NewCond(&q.recvLock) // 创建条件变量，并关联读写锁。
+	q.sendCond = sync.NewCond(&q.recvLock)
+	q.drainCond = sync.NewCond(&q.recvLock)
+	q.done = make(chan struct{})
+	q.drained = make(chan struct{})
 	q.nodePool = sync.Pool{
 		// 当对象池中没有可用节点时，使用 New 函数创建一个新的节点。
 		New: func() any {
@@ -46,32 +142,400 @@ func NewNQueue[T any]() *NQueue[T] {
 			}
 		},
 	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	q.watch()
+	return q
+}
+
+// watch 方法按配置启动 WithCloseOnContext 和 WithStuckWatchdog 的监视 goroutine，它们在 q.done 关闭时退出。
+func (q *NQueue[T]) watch() {
+	if q.closeCtx != nil {
+		go q.closeOnContext(q.closeCtx, q.done)
+	}
+	if q.onStuck != nil {
+		go q.watchStuck(q.done)
+	}
+}
+
+// closeOnContext 方法在 ctx 结束时关闭队列；队列先被手动关闭时直接退出。
+// done 为启动时的关闭通道，Reset 替换 q.done 后旧的 goroutine 不会关闭重新打开的队列。
+func (q *NQueue[T]) closeOnContext(ctx context.Context, done <-chan struct{}) {
+	select {
+	case <-ctx.Done():
+		q.Close()
+	case <-done:
+	}
+}
+
+// NewNQueueWithCap 函数用于创建一个有容量上限的 NQueue 实例。
+// 当队列中的元素数量达到 capacity 时，Enqueue 会阻塞，直到有元素出队或队列关闭。
+// capacity 小于等于 0 时等同于 NewNQueue，队列不限制容量。
+func NewNQueueWithCap[T any](capacity int, opts ...Option[T]) *NQueue[T] {
+	q := NewNQueue[T](opts...)
+	if capacity > 0 {
+		q.capacity = int64(capacity)
+	}
 	return q
 }
 
 // Close 方法用于关闭队列，将队列状态设置为 false，并广播通知所有等待的 goroutine。
 func (q *NQueue[T]) Close() {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	q.close()
+}
+
+// close 方法是 Close 的实现，队列已经关闭时什么也不做。调用方需持有 recvLock。
+func (q *NQueue[T]) close() {
+	if !q.status {
+		return // 队列已经关闭。
+	}
+
+	q.status = false        // 设置队列状态为关闭。
+	close(q.done)           // 通知监视 goroutine 退出。
+	q.recvCond.Broadcast()  // 广播通知所有等待的 goroutine，队列状态已改变。
+	q.sendCond.Broadcast()  // 唤醒等待空位的入队者，让它们返回 ErrQueueClosed。
+	q.drainCond.Broadcast() // 唤醒 WaitDrain 的等待者重新检查状态。
+	if q.timer != nil {
+		q.timer.Stop()
+		q.release(math.MaxInt64) // 尚未到期的元素立即放入队列，关闭后仍可出队。
+	}
+	if q.isEmpty() {
+		close(q.drained) // 关闭时已经没有剩余元素，队列直接进入终止状态。
+		q.unbind()
+	}
+	if q.spill != nil && q.spill.n == 0 {
+		q.spill.remove() // 关闭后不会再有元素溢出，删除已经不再使用的溢出文件。
+	}
+	if q.hooks != nil {
+		q.hooks.OnClose()
+	}
+}
+
+// CloseAndDrain 方法关闭队列，然后阻塞等待消费者取走并确认所有剩余的元素（包括尚未到期的延迟元素）。
+// 队列被取空时返回 nil；ctx 先结束时返回 ctx.Err()，队列保持关闭，剩余元素仍可继续出队。
+func (q *NQueue[T]) CloseAndDrain(ctx context.Context) error {
+	q.Close()
+	return q.WaitDrain(ctx)
+}
+
+// CloseAndDiscard 方法关闭队列并立即丢弃所有待出队的元素，返回丢弃的元素数量。
+// 开启 WithSpill 时磁盘上的元素也会被丢弃，尚未到期的延迟元素在关闭时已放入队列，一并丢弃。
+// 每个被丢弃的元素以 DropDiscarded 报告给 WithOnDrop 设置的回调，但不会转发到死信队列。
+// 尚未确认的元素不受影响，确认后队列才进入终止状态。
+func (q *NQueue[T]) CloseAndDiscard() int {
+	q.schedPoint()
 	q.recvLock.Lock()
 	defer q.recvLock.Unlock()
-	q.status = false       // 设置队列状态为关闭。
-	q.recvCond.Broadcast() // 广播通知所有等待的 goroutine，队列状态已改变。
+	q.close()
+	return q.discard()
+}
+
+// discard 方法丢弃所有待出队的元素并以 DropDiscarded 报告，返回丢弃的元素数量。调用方需持有 recvLock。
+func (q *NQueue[T]) discard() int {
+	ts := q.popBatch(int(q.count.Load()))
+	for _, v := range ts {
+		q.drop(v, DropDiscarded)
+	}
+	return len(ts)
+}
+
+// Reset 方法把一个已关闭的队列恢复为刚创建时的打开状态，使对象池中的队列可以被复用而不必重新分配。
+// 队列中剩余的元素按 CloseAndDiscard 的方式丢弃，统计信息被清零，溢出文件被删除，选项配置保持不变，
+// WithCloseOnContext 和 WithStuckWatchdog 的监视 goroutine 会重新启动（绑定的 context 已结束时队列会立即再次关闭）。
+// 队列仍处于打开状态或还有尚未确认的元素时返回 ErrQueueInUse。
+//
+// Reset 之后 Done 和 Drained 返回新的通道。调用方需保证 Reset 期间没有其他 goroutine 在使用这个队列。
+func (q *NQueue[T]) Reset() error {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	if q.status || q.inflight > 0 {
+		return ErrQueueInUse
+	}
+
+	q.discard()
+	if q.spill != nil {
+		q.spill.remove()
+		q.spill.roff, q.spill.woff, q.spill.err = 0, 0, nil
+	}
+	q.createdAt = time.Now()
+	q.enqueued, q.dequeued, q.dropped, q.dlqFailed, q.peak = 0, 0, 0, 0, 0
+	q.wakeups, q.exceeded = 0, false
+	q.waits, q.waitNext = q.waits[:0], 0
+	q.done = make(chan struct{})
+	q.drained = make(chan struct{})
+	q.status = true
+	q.watch()
+	return nil
+}
+
+// waitWithContext 方法在持有 recvLock 的前提下，阻塞在条件变量 cond 上，直到 ready 返回 true 或 ctx 结束。
+// 所有支持 context 的方法都通过它等待，语义参见 waitCond。
+func (q *NQueue[T]) waitWithContext(ctx context.Context, cond *sync.Cond, ready func() bool) error {
+	return waitCond(ctx, cond, ready, q.park)
+}
+
+// waitCond 函数在持有 cond.L 的前提下调用 wait 阻塞在 cond 上，直到 ready 返回 true 或 ctx 结束。
+// 各种队列实现共用它，保证取消语义一致：
+//   - ready 总是先于 ctx 检查，条件已满足时即使 ctx 已取消也返回 nil（元素优先于取消）；
+//   - ctx 结束时返回 ctx.Err()；
+//   - ctx 的唤醒通过 context.AfterFunc 注册，方法返回前注销，不会遗留 goroutine。
+func waitCond(ctx context.Context, cond *sync.Cond, ready func() bool, wait func(*sync.Cond)) error {
+	if ready() {
+		return nil
+	}
+
+	if ctx.Done() == nil {
+		// 不可取消的 context（例如 context.Background()）无需注册唤醒回调。
+		for !ready() {
+			wait(cond)
+		}
+		return nil
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		cond.L.Lock()
+		cond.Broadcast() // ctx 结束时唤醒所有等待者，由它们各自检查 ctx.Err()。
+		cond.L.Unlock()
+	})
+	defer stop()
+
+	for !ready() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wait(cond)
+	}
+	return nil
+}
+
+// park 方法在 cond 上阻塞等待一次，并记录正在等待元素的消费者数量。调用方需持有 recvLock。
+func (q *NQueue[T]) park(cond *sync.Cond) {
+	if cond != q.recvCond {
+		q.wait(cond)
+		return
+	}
+
+	q.parked++
+	q.wait(cond)
+	q.parked--
+}
+
+// wakeConsumers 方法在 n 个新元素入队后唤醒等待的消费者。调用方需持有 recvLock。
+// 默认广播唤醒所有等待者；开启 WithLazyWakeup 后，没有消费者阻塞时跳过唤醒，单个元素入队时只唤醒一个。
+// 消费者只会在队列为空时阻塞，并且阻塞前已计入 parked，因此不会出现队列中有元素而消费者一直阻塞的情况。
+func (q *NQueue[T]) wakeConsumers(n int64) {
+	if q.lazyWake {
+		if q.parked == 0 {
+			return
+		}
+		if n == 1 {
+			q.recvCond.Signal()
+		} else {
+			q.recvCond.Broadcast()
+		}
+	} else {
+		q.recvCond.Broadcast()
+	}
+	q.wakeups++
+}
+
+// canEnqueue 方法判断入队操作是否可以继续：队列已关闭或仍有空位。调用方需持有 recvLock。
+func (q *NQueue[T]) canEnqueue() bool {
+	return !q.status || q.capacity == 0 || q.count.Load() < q.capacity
+}
+
+// canDequeue 方法判断出队操作是否可以继续：队列非空或已关闭。调用方需持有 recvLock。
+func (q *NQueue[T]) canDequeue() bool {
+	return q.head != nil || !q.status
+}
+
+// isEmpty 方法判断队列是否已被取空：没有待出队的元素，也没有未确认或尚未到期的元素。调用方需持有 recvLock。
+func (q *NQueue[T]) isEmpty() bool {
+	return q.count.Load() == 0 && q.inflight == 0 && q.delays.Len() == 0
 }
 
 // 插入，将给定的值v放在队列的尾部
 // Enqueue 方法用于将一个值 v 插入到队列的尾部。
+// 如果队列有容量上限且已满，会阻塞直到有空位；设置了 WithOverflow 的丢弃策略时不会阻塞，参见 OverflowPolicy。
 // 如果队列已关闭，返回一个错误。
 func (q *NQueue[T]) Enqueue(v T) error {
+	return q.EnqueueContext(context.Background(), v)
+}
+
+// EnqueueContext 方法与 Enqueue 相同，但在等待空位时会响应 ctx 的取消。
+// ctx 结束时返回 ctx.Err()；队列已关闭时返回 ErrQueueClosed。
+func (q *NQueue[T]) EnqueueContext(ctx context.Context, v T) error {
+	q.schedPoint()
 	q.recvLock.Lock()
 	defer q.recvLock.Unlock()
 
+	if q.overflow(v) {
+		return nil // 按 OverflowDropNewest 丢弃。
+	}
+	if err := q.waitWithContext(ctx, q.sendCond, q.canEnqueue); err != nil {
+		return err
+	}
+
 	if !q.status {
 		return ErrQueueClosed // 如果队列已关闭，返回自定义错误
 	}
 
+	return q.push(v)
+}
+
+// TryEnqueue 方法是一个非阻塞的入队方法：队列有空位时插入值 v 并返回 true，队列已满或已关闭时立即返回 false。
+// 它不受 WithOverflow 的丢弃策略影响，队列已满时既不丢弃 v 也不淘汰已有的元素，由调用方决定如何处理；
+// 开启 WithSpill 且写入溢出文件失败时同样返回 false。
+func (q *NQueue[T]) TryEnqueue(v T) bool {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	if !q.status || !q.canEnqueue() {
+		return false
+	}
+	return q.push(v) == nil
+}
+
+// EnqueueBatch 方法按顺序把 vs 中的所有元素插入队列尾部，返回被接受的元素数量。
+// 元素在一次加锁中成批链接到队列，元素数量只更新一次，等待的消费者也只唤醒一次，比逐个调用 Enqueue 开销更小。
+// 有容量上限的队列在空位不足时先插入放得下的部分，再阻塞等待剩余元素的空位，其他生产者的元素可能穿插其间；
+// 设置了 WithOverflow 的丢弃策略时不会阻塞，被丢弃的元素也计入返回值。
+// 队列关闭或写入 WithSpill 的溢出文件失败时停止并返回错误，此时 vs[n:] 没有入队。
+func (q *NQueue[T]) EnqueueBatch(vs []T) (n int, err error) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	for n < len(vs) {
+		if q.overflow(vs[n]) {
+			n++ // 按 OverflowDropNewest 丢弃。
+			continue
+		}
+		q.waitWithContext(context.Background(), q.sendCond, q.canEnqueue)
+		if !q.status {
+			return n, ErrQueueClosed
+		}
+
+		m := int64(len(vs) - n)
+		if q.capacity > 0 {
+			m = min(m, q.capacity-q.count.Load())
+		}
+		if room, limited := q.memRoom(); limited {
+			if room == 0 {
+				// 内存已满或磁盘上已有元素，逐个经过 push 写入溢出文件。
+				if err = q.push(vs[n]); err != nil {
+					return n, err
+				}
+				n++
+				continue
+			}
+			m = min(m, room)
+		}
+
+		q.pushChain(q.newChain(vs[n : n+int(m)]))
+		n += int(m)
+	}
+	return n, nil
+}
+
+// newChain 方法为 vs 中的元素创建一条以 nil 结尾的链表并返回链表头，vs 不能为空。
+func (q *NQueue[T]) newChain(vs []T) *node[T] {
+	first := q.newNode(vs[0])
+	last := first
+	for _, v := range vs[1:] {
+		last.next = q.newNode(v)
+		last = last.next
+	}
+	return first
+}
+
+// EnqueueLen 方法与 Enqueue 相同，并返回插入后队列中元素的数量，可以用于简单的流量控制。
+// 返回值在加锁期间读取，反映的是插入那一刻的长度，之后可能已被其他 goroutine 改变。
+// 插入成功时返回值至少为 1；队列已关闭、元素按 OverflowDropNewest 被丢弃或写入 WithSpill 的溢出文件失败时不会入队并返回 0。
+func (q *NQueue[T]) EnqueueLen(v T) int {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	if q.overflow(v) {
+		return 0
+	}
+	q.waitWithContext(context.Background(), q.sendCond, q.canEnqueue)
+	if !q.status {
+		return 0
+	}
+
+	if q.push(v) != nil {
+		return 0
+	}
+	return int(q.count.Load())
+}
+
+// EnqueueTagged 方法与 Enqueue 相同，但为元素附带一个生产者标签，出队时可以通过 DequeueTagged 取回，
+// 用于排查多生产者场景下的公平性和饥饿问题。不使用标签时没有额外开销。
+// 标签随节点移动，Transfer、Swap、Cursor 和 DequeueAck 的重新投递都会保留它。
+func (q *NQueue[T]) EnqueueTagged(tag string, v T) error {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	if q.overflow(v) {
+		return nil
+	}
+	q.waitWithContext(context.Background(), q.sendCond, q.canEnqueue)
+	if !q.status {
+		return ErrQueueClosed
+	}
+
+	n := q.newNode(v)
+	if tag != "" {
+		n.metadata().tag = tag
+	}
+	return q.pushNode(n)
+}
+
+// push 方法将值 v 链接到队列尾部，并通知等待的出队者。调用方需持有 recvLock。
+// 只有开启 WithSpill 且写入溢出文件失败时才会返回错误。
+func (q *NQueue[T]) push(v T) error {
+	return q.pushNode(q.newNode(v))
+}
+
+// newNode 方法从对象池中取出一个节点并设置它的值。
+func (q *NQueue[T]) newNode(v T) *node[T] {
 	n := q.nodePool.Get().(*node[T]) // 从对象池中获取一个节点。
 	n.value = v                      // 设置节点的值为 v。
 	n.next = nil                     // 设置节点的下一个节点指针为 nil。
+	if q.stamped {
+		n.metadata().enqueuedAt = time.Now().UnixNano() // 记录入队时间。
+	}
+	return n
+}
+
+// pushNode 方法将节点 n 链接到队列尾部，并通知等待的出队者。调用方需持有 recvLock。
+// 开启 WithSpill 且内存中的元素已达到上限时，节点会被写入溢出文件。
+func (q *NQueue[T]) pushNode(n *node[T]) error {
+	if spilled, err := q.spillNode(n); spilled || err != nil {
+		return err
+	}
+
+	q.linkNode(n)
+	if q.sizeOf != nil {
+		q.bytes.Add(int64(q.sizeOf(n.value)))
+	}
+	q.added(1) // 队列元素数量加 1，并通知等待的 goroutine，队列中有新元素入队。
+	return nil
+}
 
+// linkNode 方法只把节点 n 链接到队列尾部，不更新元素数量。调用方需持有 recvLock。
+func (q *NQueue[T]) linkNode(n *node[T]) {
 	if q.head == nil {
 		q.head = n // 如果队列为空，将头节点和尾节点都指向新节点。
 	} else {
@@ -84,10 +548,16 @@ func (q *NQueue[T]) Enqueue(v T) error {
 			q.tail = n        // 更新尾节点为新节点。
 		}
 	}
+}
 
-	q.count++              // 队列元素数量加 1。
-	q.recvCond.Broadcast() // 广播通知所有等待的 goroutine，队列中有新元素入队。
-	return nil
+// recycle 方法重置节点并放回对象池，以便复用。
+func (q *NQueue[T]) recycle(n *node[T]) {
+	n.value = q.zeroValue // 将节点的值重置为泛型类型的零值。
+	n.next = nil          // 将节点的下一个节点指针置为 nil。
+	if n.meta != nil {
+		*n.meta = nodeMeta{}
+	}
+	q.nodePool.Put(n)
 }
 
 // 不阻塞
@@ -97,91 +567,585 @@ func (q *NQueue[T]) Dequeue() (t T, ok bool, isClose bool) {
 	return
 }
 
-// 移除，删除并返回队列头部的值,如果队列为空，则返回nil
+// 移除，删除并返回队列头部的值,如果队列为空，则返回零值且 ok 为 false
 // dequeue 方法是一个私有方法，用于执行实际的出队操作。
 // 返回出队的值、是否成功出队的标志和队列是否已关闭的标志。
 func (q *NQueue[T]) dequeue() (t T, ok bool, isClose bool) {
+	q.schedPoint()
 	q.recvLock.Lock()
 	defer q.recvLock.Unlock()
+	return q.pop()
+}
+
+// pop 方法从队列头部取出一个值。调用方需持有 recvLock。
+// 返回出队的值、是否成功出队的标志和队列是否已关闭的标志。
+func (q *NQueue[T]) pop() (t T, ok bool, isClose bool) {
 	isClose = !q.status // 获取队列是否已关闭的标志。
-	if q.head == nil {
+	n := q.popNode()
+	if n == nil {
 		t = q.zeroValue // 如果队列为空，返回泛型类型的零值。
 		return
-	} else {
+	}
 
-		oldHead := q.head // 保存旧的头节点。
-		if oldHead.next == nil {
-			q.head = nil // 如果队列只有一个元素，将头节点和尾节点都置为 nil。
-		} else {
-			q.head = oldHead.next // 更新头节点为旧头节点的下一个节点。
-			if q.head == q.tail {
-				q.tail = nil // 如果新的头节点是尾节点，将尾节点置为 nil。
-			}
+	t, ok = n.value, true // 获取旧头节点的值，标记出队成功。
+	q.recycle(n)
+	return
+}
+
+// popNode 方法从队列头部摘下一个节点，队列为空时返回 nil。调用方需持有 recvLock。
+// 节点的回收由调用方负责。
+func (q *NQueue[T]) popNode() *node[T] {
+	oldHead := q.head // 保存旧的头节点。
+	if oldHead == nil {
+		return nil
+	}
+
+	if oldHead.next == nil {
+		q.head = nil // 如果队列只有一个元素，将头节点和尾节点都置为 nil。
+	} else {
+		q.head = oldHead.next // 更新头节点为旧头节点的下一个节点。
+		if q.head == q.tail {
+			q.tail = nil // 如果新的头节点是尾节点，将尾节点置为 nil。
 		}
+	}
+	oldHead.next = nil
 
-		ok = true                   // 标记出队成功。
-		q.count--                   // 队列元素数量减 1。
-		t = oldHead.value           // 获取旧头节点的值。
-		oldHead.value = q.zeroValue // 将旧头节点的值重置为泛型类型的零值。
-		oldHead.next = nil          // 将旧头节点的下一个节点指针置为 nil。
-		q.nodePool.Put(oldHead)     // 将旧头节点放回对象池，以便复用。
+	if q.sizeOf != nil {
+		q.bytes.Add(-int64(q.sizeOf(oldHead.value)))
+	}
+	if q.stamped {
+		q.recordWait(oldHead)
+	}
+	q.removed(1) // 队列元素数量减 1。
+	q.refill()
+	return oldHead
+}
+
+// added 方法在 n 个元素进入队列后更新元素数量和统计，并唤醒等待的消费者。调用方需持有 recvLock。
+func (q *NQueue[T]) added(n int64) {
+	c := q.count.Add(n)
+	q.enqueued += uint64(n)
+	if c > q.peak {
+		q.peak = c
+	}
+	if q.softLimit > 0 && !q.exceeded && c > q.softLimit {
+		q.exceeded = true
+		go q.onExceed(int(c))
+	}
+	if q.hooks != nil {
+		q.hooks.OnEnqueue(int(n), c)
+	}
+	q.wakeConsumers(n)
+}
 
+// removed 方法在 n 个元素离开队列后更新元素数量和统计，通知等待空位和等待取空的 goroutine。调用方需持有 recvLock。
+func (q *NQueue[T]) removed(n int64) {
+	c := q.count.Add(-n)
+	q.dequeued += uint64(n)
+	if q.exceeded && c <= q.softLimit/2 {
+		q.exceeded = false // 回落到软上限的一半以下后重新开始检测，避免在上限附近反复触发。
+	}
+	if q.capacity > 0 {
+		q.sendCond.Broadcast() // 有界队列腾出了空位，通知等待的入队者。
+	}
+	if q.hooks != nil {
+		q.hooks.OnDequeue(int(n), c)
+	}
+	if c != 0 {
 		return
 	}
+	if q.onEmpty != nil {
+		go q.onEmpty() // 只有元素被移除时才会走到这里，因此每次都是从非空到空的转变。
+	}
+	if q.isEmpty() {
+		q.emptied()
+	}
+}
+
+// emptied 方法在队列被取空且没有未确认的元素时调用，通知 WaitDrain 的等待者；
+// 队列已关闭时不会再有新元素，关闭 drained 通道。调用方需持有 recvLock。
+func (q *NQueue[T]) emptied() {
+	q.drainCond.Broadcast()
+	if !q.status {
+		select {
+		case <-q.drained:
+		default:
+			close(q.drained)
+			q.unbind()
+		}
+	}
+}
+
+// popChain 方法从队列头部摘下最多 max 个节点，返回摘下的链表头和节点数量。调用方需持有 recvLock。
+// 摘下的链表以 nil 结尾，节点的回收由调用方负责。
+func (q *NQueue[T]) popChain(max int) (first *node[T], n int64) {
+	if q.head == nil || max <= 0 {
+		return nil, 0
+	}
+
+	first = q.head
+	last := first
+	for n = 1; n < int64(max) && last.next != nil; n++ {
+		last = last.next
+	}
+
+	q.head = last.next
+	last.next = nil
+	if q.head == nil || q.head == q.tail {
+		q.tail = nil // 队列为空或只剩一个元素时，尾节点置为 nil。
+	}
+
+	if q.sizeOf != nil {
+		q.bytes.Add(-q.chainBytes(first))
+	}
+	if q.stamped {
+		for m := first; m != nil; m = m.next {
+			q.recordWait(m)
+		}
+	}
+	q.removed(n)
+	q.refill()
+	return
+}
+
+// pushChain 方法把以 first 开头、以 nil 结尾的链表按原顺序链接到队列尾部。调用方需持有 recvLock。
+// 调用方负责检查关闭状态和容量上限，开启 WithSpill 时还需保证链表能全部放入内存，参见 memRoom。
+func (q *NQueue[T]) pushChain(first *node[T]) {
+	last, n := chainTail(first)
+	if q.head == nil {
+		q.head = first
+		if first != last {
+			q.tail = last
+		}
+	} else if q.tail == nil {
+		q.head.next = first // 原队列只有一个元素。
+		q.tail = last
+	} else {
+		q.tail.next = first
+		q.tail = last
+	}
+
+	if q.sizeOf != nil {
+		q.bytes.Add(q.chainBytes(first))
+	}
+	q.added(n)
+}
+
+// pushFrontChain 方法把以 first 开头、以 nil 结尾的链表按原顺序放回队列头部。调用方需持有 recvLock。
+// 不受容量上限和关闭状态的限制，用于放回已经占用过队列位置的元素。
+func (q *NQueue[T]) pushFrontChain(first *node[T]) {
+	last, n := chainTail(first)
+	if q.sizeOf != nil {
+		q.bytes.Add(q.chainBytes(first)) // 在链接到原队列之前计算，只统计放回的节点。
+	}
+
+	if q.head != nil {
+		last.next = q.head
+		if q.tail == nil {
+			q.tail = q.head // 原队列只有一个元素时，旧头节点成为尾节点。
+		}
+	} else if first != last {
+		q.tail = last
+	}
+	q.head = first
+	q.added(n)
+}
+
+// chainBytes 方法返回以 first 开头的链表中所有元素的总字节数，未设置 sizeOf 时返回 0。
+func (q *NQueue[T]) chainBytes(first *node[T]) (size int64) {
+	if q.sizeOf == nil {
+		return 0
+	}
+	for n := first; n != nil; n = n.next {
+		size += int64(q.sizeOf(n.value))
+	}
+	return
+}
+
+// chainTail 函数返回以 first 开头、以 nil 结尾的链表的最后一个节点和节点数量。
+func chainTail[T any](first *node[T]) (last *node[T], n int64) {
+	last, n = first, 1
+	for last.next != nil {
+		last = last.next
+		n++
+	}
+	return
 }
 
 // 阻塞    返回值t
 // DequeueWait 方法是一个阻塞的出队方法，会一直等待直到有元素出队或队列关闭。
 // 返回出队的值、是否成功出队的标志和队列是否已关闭的标志。
+// DequeueWait 不会虚假返回：被唤醒后会在持有锁的情况下重新检查条件，条件不满足时继续阻塞，
+// 因此永远不会返回 ok 和 isClose 都为 false 的结果。
 func (q *NQueue[T]) DequeueWait() (t T, ok bool, isClose bool) {
-	for {
-		t, ok, isClose = q.dequeue() // 尝试出队。
-		if ok {
-			return // 如果出队成功，返回结果。
+	t, ok, isClose, _ = q.DequeueContext(context.Background())
+	return
+}
+
+// DequeueContext 方法与 DequeueWait 相同，但在等待时会响应 ctx 的取消。
+// ctx 结束且队列中没有元素时返回 ctx.Err()，此时 ok 为 false。
+func (q *NQueue[T]) DequeueContext(ctx context.Context) (t T, ok bool, isClose bool, err error) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	if err = q.waitWithContext(ctx, q.recvCond, q.canDequeue); err != nil {
+		t, isClose = q.zeroValue, !q.status
+		return
+	}
+
+	t, ok, isClose = q.pop()
+	return
+}
+
+// DequeueTagged 方法与 DequeueWait 相同，同时返回元素入队时由 EnqueueTagged 附带的标签；
+// 通过其他方式入队的元素标签为空字符串。
+func (q *NQueue[T]) DequeueTagged() (t T, tag string, ok bool, isClose bool) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	q.waitWithContext(context.Background(), q.recvCond, q.canDequeue)
+	isClose = !q.status
+	n := q.popNode()
+	if n == nil {
+		return q.zeroValue, "", false, isClose
+	}
+
+	t, tag = n.value, n.tag()
+	q.recycle(n)
+	return t, tag, true, isClose
+}
+
+// DequeueAck 方法是一个阻塞的出队方法，用于至少一次（at-least-once）的消费模式。
+// 返回出队的值、确认回调和是否成功出队的标志；队列关闭且为空时 ok 为 false。
+// 处理完成后必须调用一次确认回调：参数为 false 表示确认完成，为 true 表示处理失败，
+// 元素会按 WithRequeuePolicy 设置的策略重新放回队列，默认放回头部，下一次出队时优先投递。回调只有第一次调用生效。
+// 在确认之前，该元素不计入 Count()，而是计入 InFlight()；WaitDrain 会等待它被确认。
+// 需要知道元素已被投递过几次时使用 DequeueAckMeta。
+func (q *NQueue[T]) DequeueAck() (t T, ack func(requeue bool), ok bool) {
+	t, _, ack, ok = q.DequeueAckMeta()
+	return
+}
+
+// DequeueAckMeta 方法与 DequeueAck 相同，同时返回这是该元素的第几次投递，第一次出队时为 1。
+// 每次通过确认回调重新放回队列，下一次投递的次数加 1；元素被确认或通过其他方式出队后计数随之清除。
+// 消费者可以据此在多次失败后放弃重试，也可以由 WithMaxAttempts 在达到上限后自动放弃。
+// 投递次数随节点保存，Transfer、Swap 和 Cursor 都会保留它；通过 WithSpill 溢出到磁盘的元素不会有重新投递的记录。
+func (q *NQueue[T]) DequeueAckMeta() (t T, attempt int, ack func(requeue bool), ok bool) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	q.waitWithContext(context.Background(), q.recvCond, q.canDequeue)
+	if q.head == nil {
+		return q.zeroValue, 0, func(bool) {}, false
+	}
+
+	q.inflight++ // 先计入未确认数量，避免 popNode 误判队列已被取空。
+	n := q.popNode()
+	t, attempt = n.value, int(n.attempts())+1
+
+	var acked atomic.Bool
+	ack = func(requeue bool) {
+		if !acked.CompareAndSwap(false, true) {
+			return
 		}
 
-		if isClose {
-			return // 如果队列已关闭，返回结果。
+		q.schedPoint()
+		q.recvLock.Lock()
+		defer q.recvLock.Unlock()
+		q.inflight--
+		if requeue {
+			q.requeue(n)
+			return
+		}
+		q.recycle(n)
+		if q.isEmpty() {
+			q.emptied()
+		}
+	}
+	return t, attempt, ack, true
+}
+
+// DequeueBatchAck 方法是 DequeueAck 的批量版本，阻塞等待直到队列中至少有一个元素，然后一次取出最多 max 个元素。
+// 返回取出的元素、确认回调和是否成功出队的标志；队列关闭且为空或 max 小于等于 0 时 ok 为 false。
+// 处理完成后必须调用一次确认回调，参数 acked 表示前 acked 个元素处理成功，其余元素按原顺序重新放回队列，
+// 放回的位置由 WithRequeuePolicy 决定，默认放回头部，下一次出队时优先投递；acked 会被限制在 [0, len(ts)] 范围内。回调只有第一次调用生效。
+// 在确认之前，整批元素都不计入 Count()，而是计入 InFlight()；WaitDrain 会等待它们被确认。
+func (q *NQueue[T]) DequeueBatchAck(max int) (ts []T, ack func(acked int), ok bool) {
+	if max <= 0 {
+		return nil, func(int) {}, false
+	}
+
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	q.waitWithContext(context.Background(), q.recvCond, q.canDequeue)
+	if q.head == nil {
+		return nil, func(int) {}, false
+	}
+
+	reserved := int64(min(max, int(q.count.Load())))
+	q.inflight += reserved // 先计入未确认数量，避免 popChain 误判队列已被取空。
+	first, n := q.popChain(max)
+	q.inflight += n - reserved // 开启 WithSpill 时 popChain 只摘下内存中的节点，数量可能更少。
+	ts = make([]T, 0, n)
+	for m := first; m != nil; m = m.next {
+		ts = append(ts, m.value)
+	}
+
+	var once atomic.Bool
+	ack = func(acked int) {
+		if !once.CompareAndSwap(false, true) {
+			return
 		}
 
+		q.schedPoint()
 		q.recvLock.Lock()
-		if q.status && q.count == 0 {
-			q.recvCond.Wait() // 如果队列处于打开状态且为空，阻塞等待。
+		defer q.recvLock.Unlock()
+		q.inflight -= n
+		for ; acked > 0 && first != nil; acked-- {
+			next := first.next
+			q.recycle(first)
+			first = next
+		}
+		if first != nil {
+			q.requeue(first)
+		} else if q.isEmpty() {
+			q.emptied()
+		}
+	}
+	return ts, ack, true
+}
+
+// DequeueBatch 方法是一个非阻塞的批量出队方法，一次取出最多 max 个元素；队列为空或 max 小于等于 0 时 ts 为空。
+// 元素在一次加锁中成批摘下，元素数量只更新一次。isClose 的含义与 Dequeue 相同。
+func (q *NQueue[T]) DequeueBatch(max int) (ts []T, isClose bool) {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	return q.popBatch(max), !q.status
+}
+
+// popBatch 方法从队列头部成批取出最多 max 个元素的值并回收节点。调用方需持有 recvLock。
+func (q *NQueue[T]) popBatch(max int) (ts []T) {
+	for len(ts) < max {
+		// 开启 WithSpill 时 popChain 只摘下内存中的节点，读回磁盘上的元素后继续摘取。
+		first, n := q.popChain(max - len(ts))
+		if n == 0 {
+			break
 		}
-		q.recvLock.Unlock()
+		if ts == nil {
+			ts = make([]T, 0, n)
+		}
+		for first != nil {
+			next := first.next
+			ts = append(ts, first.value)
+			q.recycle(first)
+			first = next
+		}
+	}
+	return
+}
+
+// DequeueBatchWait 方法阻塞等待，直到队列中至少有一个元素或队列关闭，然后一次取出最多 max 个元素。
+// ctx 结束且队列中没有元素时返回 ctx.Err()，需要超时时使用 context.WithTimeout。max 小于等于 0 时直接返回。
+func (q *NQueue[T]) DequeueBatchWait(ctx context.Context, max int) (ts []T, isClose bool, err error) {
+	if max <= 0 {
+		return nil, !q.Status(), nil
+	}
+
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+
+	if err = q.waitWithContext(ctx, q.recvCond, q.canDequeue); err != nil {
+		return nil, !q.status, err
 	}
+
+	return q.popBatch(max), !q.status, nil
+}
+
+// WaitDrain 方法阻塞等待，直到队列中的元素被全部取出。
+// 队列关闭后仍会继续等待剩余元素被消费者取走；ctx 结束时返回 ctx.Err()。
+func (q *NQueue[T]) WaitDrain(ctx context.Context) error {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	return q.waitWithContext(ctx, q.drainCond, q.isEmpty)
 }
 
 // DequeueFunc 方法是一个阻塞的出队方法，会不断出队元素并调用传入的函数 fn 进行处理。
 // 直到 fn 函数返回 false 或队列关闭且为空。
 // 返回一个错误信息，如果队列关闭且为空，返回相应的错误。
+//
+// Close 之后 DequeueFunc 不会立即返回：关闭前已入队的元素会被逐个取出并交给 fn，
+// 关闭后取出的元素 isClose 为 true。判断队列为空与判断队列已关闭在同一次加锁中完成，
+// 而关闭后的入队都会被拒绝，因此返回 ErrQueueClosedEmpty 时不会有元素被遗漏。
 func (q *NQueue[T]) DequeueFunc(fn DequeueFunc[T]) (err error) {
 	for {
-
-		t, ok, isClose := q.dequeue() // 尝试出队。
-		if ok {
-			if !fn(t, isClose) {
-				return // 如果 fn 函数返回 false，停止出队并返回。
-			}
-		} else if isClose {
+		t, ok, isClose := q.DequeueWait() // 阻塞出队。
+		if !ok {
 			return ErrQueueClosedEmpty // 返回自定义错误：队列已关闭且为空
 		}
 
-		q.recvLock.Lock()
+		if !fn(t, isClose) {
+			return // 如果 fn 函数返回 false，停止出队并返回。
+		}
+	}
+}
 
-		if q.status && q.count == 0 {
-			q.recvCond.Wait() // 如果队列处于打开状态且为空，阻塞等待。
+// DequeueFuncContext 方法与 DequeueFunc 相同，但在 ctx 结束时停止出队并返回 ctx.Err()，队列本身不会被关闭，
+// 其他消费者可以继续出队。ctx 在每次出队之前检查，已经取出的元素总会交给 fn，不会因 ctx 结束而丢失。
+func (q *NQueue[T]) DequeueFuncContext(ctx context.Context, fn DequeueFunc[T]) (err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err // 每次出队前检查，队列中一直有元素时也能及时停止。
+		}
+
+		t, ok, isClose, err := q.DequeueContext(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrQueueClosedEmpty
 		}
 
-		q.recvLock.Unlock()
+		if !fn(t, isClose) {
+			return nil
+		}
 	}
 }
 
-// Count 方法用于获取队列中元素的数量，使用读锁保证并发安全。
+// Count 方法用于获取队列中元素的数量。
+// 读取只是一次原子加载，不需要加锁，可以在每次入队时调用以实现按负载路由。
 func (q *NQueue[T]) Count() int64 {
+	return q.count.Load()
+}
+
+// Cap 方法返回队列的容量上限，0 表示不限制容量，参见 NewNQueueWithCap。
+func (q *NQueue[T]) Cap() int64 {
+	return q.capacity
+}
+
+// TryDequeue 方法是一个从不阻塞的出队方法，与 Dequeue 相同但不返回关闭状态；队列为空时 ok 为 false。
+func (q *NQueue[T]) TryDequeue() (t T, ok bool) {
+	t, ok, _ = q.Dequeue()
+	return
+}
+
+// Drain 方法在一次加锁中取出队列中当前所有待出队的元素并按出队顺序返回，队列为空时返回 nil。
+// 开启 WithSpill 时磁盘上的元素也会被读回；尚未确认和尚未到期的元素不包括在内。
+func (q *NQueue[T]) Drain() []T {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	return q.popBatch(int(q.count.Load()))
+}
+
+// Peek 方法返回队列头部的值但不将其移除；队列为空时 ok 为 false。
+func (q *NQueue[T]) Peek() (t T, ok bool) {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	if q.head == nil {
+		return q.zeroValue, false
+	}
+	return q.head.value, true
+}
+
+// Snapshot 方法按先进先出的顺序返回队列中所有待出队元素的副本，不会移除元素。
+// 复制期间持有读锁，所有入队和出队都会等待复制完成，参见 ConsistentSnapshot。
+func (q *NQueue[T]) Snapshot() []T {
 	q.recvLock.RLock()
 	defer q.recvLock.RUnlock()
-	return q.count
+	return q.snapshot(int(q.count.Load()))
+}
+
+// ConsistentSnapshot 方法返回队列内容的线性一致快照：结果恰好是某一时刻队列中的全部待出队元素，按先进先出排列。
+// 复制期间所有入队和出队操作都会被暂停，暂停时间与队列长度成正比，队列很长时会明显增加生产者和消费者的延迟。
+// 只需要查看头部元素时应使用 SnapshotN。
+//
+// 由于 NQueue 的所有修改都在同一把锁下完成，持有读锁即可让队列静止，因此它与 Snapshot 的实现相同；
+// 单独提供这个方法是为了让调用方明确地选择这一代价。
+func (q *NQueue[T]) ConsistentSnapshot() []T {
+	return q.Snapshot()
+}
+
+// SnapshotN 方法按先进先出的顺序返回队列头部最多 max 个元素的副本，不会移除元素。
+// 适合只需要查看大量积压中头部元素的场景，开销只与 max 有关。
+// SnapshotN(1) 与 Peek() 返回相同的元素。max 小于等于 0 时返回 nil。
+func (q *NQueue[T]) SnapshotN(max int) []T {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	return q.snapshot(max)
+}
+
+// snapshot 方法从头部开始复制最多 max 个元素。调用方需持有 recvLock。
+func (q *NQueue[T]) snapshot(max int) []T {
+	if max <= 0 || q.head == nil {
+		return nil
+	}
+
+	ts := make([]T, 0, min(int64(max), q.count.Load()))
+	for n := q.head; n != nil && len(ts) < max; n = n.next {
+		ts = append(ts, n.value)
+	}
+	if q.spill != nil {
+		q.spill.each(max-len(ts), func(v T) { ts = append(ts, v) })
+	}
+	return ts
+}
+
+// ByteLen 方法返回队列中待出队元素的总字节数，由 WithSizeOf 设置的函数计算。
+// 总字节数在入队和出队时增量维护，读取只是一次原子加载；未设置 WithSizeOf 时总是返回 0。
+func (q *NQueue[T]) ByteLen() int64 {
+	return q.bytes.Load()
+}
+
+// OldestAge 方法返回队列头部元素已经在队列中等待的时间，需要开启 WithTimestamps。
+// 队列为空或未开启 WithTimestamps 时 ok 为 false。
+func (q *NQueue[T]) OldestAge() (age time.Duration, ok bool) {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	if q.head == nil || !q.stamped {
+		return 0, false
+	}
+	return time.Duration(time.Now().UnixNano() - q.head.enqueuedAt()), true
+}
+
+// InFlight 方法用于获取已通过 DequeueAck 取出但尚未确认的元素数量，
+// 也包括 ToChan 等待发送的元素和 QueueCursor 缓冲区中的元素。
+func (q *NQueue[T]) InFlight() int64 {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	return q.inflight
+}
+
+// Done 方法返回一个在队列关闭时被关闭的通道，可以用于 select 语句中等待队列关闭。
+func (q *NQueue[T]) Done() <-chan struct{} {
+	return q.done
+}
+
+// Drained 方法返回一个在队列关闭、并且所有元素都已被取走时被关闭的通道。
+// 与 Done 不同，关闭时队列中仍有元素的话，要等这些元素全部出队后通道才会被关闭；
+// 通过 DequeueAck 或 DequeueBatchAck 取出的元素还需要被确认，因为它们可能被重新放回队列；
+// QueueCursor 缓冲区中的元素同样要等整批返回或归还。
+// 通道关闭后队列不会再有新元素，可以安全地释放关联的资源。
+func (q *NQueue[T]) Drained() <-chan struct{} {
+	return q.drained
+}
+
+// IsDrained 方法判断队列是否已经关闭并被完全取空，即 Drained 返回的通道是否已被关闭。
+func (q *NQueue[T]) IsDrained() bool {
+	select {
+	case <-q.drained:
+		return true
+	default:
+		return false
+	}
 }
 
 // Status 方法用于获取队列的状态，使用读锁保证并发安全。
@@ -190,3 +1154,8 @@ func (q *NQueue[T]) Status() bool {
 	defer q.recvLock.RUnlock()
 	return q.status
 }
+
+// IsClosed 方法判断队列是否已关闭，等价于 !Status()。
+func (q *NQueue[T]) IsClosed() bool {
+	return !q.Status()
+}
@@ -1,11 +1,16 @@
 package nqueue
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 // / go test -run TestNqueue -v
@@ -156,3 +161,1241 @@ func TestLockFreeQueueManay(t *testing.T) {
 	fmt.Println(count.Load())
 	fmt.Println(time.Now())
 }
+
+// contextCase 描述一个支持 context 的方法：setup 构造一个会阻塞该方法的队列，
+// op 执行该方法，unblock 让阻塞条件得到满足。
+type contextCase struct {
+	name    string
+	setup   func() *NQueue[int]
+	op      func(ctx context.Context, q *NQueue[int]) error
+	unblock func(q *NQueue[int])
+}
+
+var contextCases = []contextCase{
+	{
+		name:  "DequeueContext",
+		setup: func() *NQueue[int] { return NewNQueue[int]() },
+		op: func(ctx context.Context, q *NQueue[int]) error {
+			_, _, _, err := q.DequeueContext(ctx)
+			return err
+		},
+		unblock: func(q *NQueue[int]) { q.Enqueue(1) },
+	},
+	{
+		name:  "DequeueBatchWait",
+		setup: func() *NQueue[int] { return NewNQueue[int]() },
+		op: func(ctx context.Context, q *NQueue[int]) error {
+			_, _, err := q.DequeueBatchWait(ctx, 8)
+			return err
+		},
+		unblock: func(q *NQueue[int]) { q.Enqueue(1) },
+	},
+	{
+		name: "WaitDrain",
+		setup: func() *NQueue[int] {
+			q := NewNQueue[int]()
+			q.Enqueue(1)
+			return q
+		},
+		op:      func(ctx context.Context, q *NQueue[int]) error { return q.WaitDrain(ctx) },
+		unblock: func(q *NQueue[int]) { q.Dequeue() },
+	},
+	{
+		name: "EnqueueContext",
+		setup: func() *NQueue[int] {
+			q := NewNQueueWithCap[int](1)
+			q.Enqueue(1)
+			return q
+		},
+		op:      func(ctx context.Context, q *NQueue[int]) error { return q.EnqueueContext(ctx, 2) },
+		unblock: func(q *NQueue[int]) { q.Dequeue() },
+	},
+}
+
+// go test -run TestContextCancellation -v
+func TestContextCancellation(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	for _, c := range contextCases {
+		t.Run(c.name+"/AlreadyCancelled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := c.op(ctx, c.setup()); !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", err)
+			}
+		})
+
+		t.Run(c.name+"/ReadyWinsOverCancel", func(t *testing.T) {
+			q := c.setup()
+			c.unblock(q)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := c.op(ctx, q); err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+		})
+
+		t.Run(c.name+"/CancelWhileWaiting", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			errc := make(chan error, 1)
+			go func() { errc <- c.op(ctx, c.setup()) }()
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+			select {
+			case err := <-errc:
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("err = %v, want context.Canceled", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("operation did not return after cancel")
+			}
+		})
+
+		t.Run(c.name+"/Deadline", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if err := c.op(ctx, c.setup()); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+			}
+		})
+
+		t.Run(c.name+"/UnblockWhileWaiting", func(t *testing.T) {
+			q := c.setup()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errc := make(chan error, 1)
+			go func() { errc <- c.op(ctx, q) }()
+			time.Sleep(10 * time.Millisecond)
+			c.unblock(q)
+			select {
+			case err := <-errc:
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("operation did not return after unblock")
+			}
+		})
+	}
+
+	// 所有取消回调都应已注销，不应遗留 goroutine。
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base {
+		t.Fatalf("goroutines = %d, want <= %d", n, base)
+	}
+}
+
+// go test -run TestDequeueFuncContext -v
+func TestDequeueFuncContext(t *testing.T) {
+	for _, q := range []Queue[int]{NewNQueue[int](), NewOrderedNQueue[int](), NewRelaxedQueue[int](2)} {
+		ctx, cancel := context.WithCancel(context.Background())
+		q.Enqueue(1)
+		q.Enqueue(2)
+
+		// 处理第一个元素时取消 ctx，循环在下一次出队之前停止，剩余元素留在队列中。
+		var got []int
+		err := q.DequeueFuncContext(ctx, func(v int, isClose bool) bool {
+			got = append(got, v)
+			cancel()
+			return true
+		})
+		if !errors.Is(err, context.Canceled) || len(got) != 1 || q.Count() != 1 || q.IsClosed() {
+			t.Fatalf("%T: err = %v, got %v, Count() = %d, IsClosed() = %v", q, err, got, q.Count(), q.IsClosed())
+		}
+
+		// 等待元素时 ctx 超时同样停止。
+		q.Dequeue()
+		ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+		if err := q.DequeueFuncContext(ctx, func(int, bool) bool { return true }); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%T: err = %v, want %v", q, err, context.DeadlineExceeded)
+		}
+		cancel()
+
+		q.Close()
+		if err := q.DequeueFuncContext(context.Background(), func(int, bool) bool { return true }); err != ErrQueueClosedEmpty {
+			t.Fatalf("%T: err = %v after Close, want %v", q, err, ErrQueueClosedEmpty)
+		}
+	}
+}
+
+// go test -run TestDequeueAck -v
+func TestDequeueAck(t *testing.T) {
+	q := NewNQueue[int]()
+	q.Enqueue(1)
+	q.Enqueue(2)
+
+	v, ack, ok := q.DequeueAck()
+	if !ok || v != 1 {
+		t.Fatalf("DequeueAck() = %d, %v, want 1, true", v, ok)
+	}
+	if q.Count() != 1 || q.InFlight() != 1 {
+		t.Fatalf("Count() = %d, InFlight() = %d, want 1, 1", q.Count(), q.InFlight())
+	}
+
+	// 未确认的元素重新放回队列头部，先于 2 被投递。
+	ack(true)
+	ack(false) // 重复调用不生效。
+	if q.Count() != 2 || q.InFlight() != 0 {
+		t.Fatalf("Count() = %d, InFlight() = %d, want 2, 0", q.Count(), q.InFlight())
+	}
+
+	v, ack, ok = q.DequeueAck()
+	if !ok || v != 1 {
+		t.Fatalf("redelivered = %d, %v, want 1, true", v, ok)
+	}
+	ack(false)
+
+	v, ack, ok = q.DequeueAck()
+	if !ok || v != 2 {
+		t.Fatalf("DequeueAck() = %d, %v, want 2, true", v, ok)
+	}
+
+	// WaitDrain 需要等待未确认的元素被确认。
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.WaitDrain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitDrain() = %v, want context.DeadlineExceeded", err)
+	}
+	ack(false)
+	if err := q.WaitDrain(context.Background()); err != nil {
+		t.Fatalf("WaitDrain() = %v, want nil", err)
+	}
+
+	q.Close()
+	if _, _, ok = q.DequeueAck(); ok {
+		t.Fatal("DequeueAck() on closed empty queue returned ok")
+	}
+}
+
+// go test -run TestDequeueBatchAck -v
+func TestDequeueBatchAck(t *testing.T) {
+	q := NewNQueue[int]()
+	for i := 1; i <= 6; i++ {
+		q.Enqueue(i)
+	}
+
+	ts, ack, ok := q.DequeueBatchAck(4)
+	if !ok || !slices.Equal(ts, []int{1, 2, 3, 4}) {
+		t.Fatalf("DequeueBatchAck(4) = %v, %v, want [1 2 3 4], true", ts, ok)
+	}
+	if q.Count() != 2 || q.InFlight() != 4 {
+		t.Fatalf("Count() = %d, InFlight() = %d, want 2, 4", q.Count(), q.InFlight())
+	}
+
+	// 只确认前两个，3 和 4 按原顺序放回头部，先于 5 被投递。
+	ack(2)
+	ack(4) // 重复调用不生效。
+	if q.Count() != 4 || q.InFlight() != 0 {
+		t.Fatalf("Count() = %d, InFlight() = %d, want 4, 0", q.Count(), q.InFlight())
+	}
+
+	ts, ack, ok = q.DequeueBatchAck(10)
+	if !ok || !slices.Equal(ts, []int{3, 4, 5, 6}) {
+		t.Fatalf("redelivered = %v, %v, want [3 4 5 6], true", ts, ok)
+	}
+
+	// WaitDrain 需要等待整批元素被确认。
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.WaitDrain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitDrain() = %v, want context.DeadlineExceeded", err)
+	}
+	ack(len(ts))
+	if err := q.WaitDrain(context.Background()); err != nil {
+		t.Fatalf("WaitDrain() = %v, want nil", err)
+	}
+
+	q.Close()
+	if _, _, ok = q.DequeueBatchAck(4); ok {
+		t.Fatal("DequeueBatchAck() on closed empty queue returned ok")
+	}
+}
+
+// go test -run TestDequeueAckMeta -v
+func TestDequeueAckMeta(t *testing.T) {
+	q := NewNQueue[string]()
+	q.EnqueueTagged("p1", "job")
+	q.Enqueue("next")
+
+	// 连续三次处理失败，投递次数依次为 1、2、3，重新投递的元素保留标签且仍排在头部。
+	for want := 1; want <= 3; want++ {
+		v, attempt, ack, ok := q.DequeueAckMeta()
+		if !ok || v != "job" || attempt != want {
+			t.Fatalf("DequeueAckMeta() = %q, %d, %v, want job, %d, true", v, attempt, ok, want)
+		}
+		ack(true)
+	}
+	v, tag, _, _ := q.DequeueTagged()
+	if v != "job" || tag != "p1" {
+		t.Fatalf("DequeueTagged() = %q, %q, want job, p1", v, tag)
+	}
+
+	// 其他元素不受影响；确认后计数清除，再次入队的元素从 1 开始。
+	v, attempt, ack, _ := q.DequeueAckMeta()
+	if v != "next" || attempt != 1 {
+		t.Fatalf("DequeueAckMeta() = %q, %d, want next, 1", v, attempt)
+	}
+	ack(false)
+	q.Enqueue("again")
+	if _, attempt, _, _ = q.DequeueAckMeta(); attempt != 1 {
+		t.Fatalf("attempt after ack = %d, want 1", attempt)
+	}
+
+	// 批量确认同样累计投递次数。
+	q.Enqueue("a")
+	q.Enqueue("b")
+	_, batchAck, _ := q.DequeueBatchAck(2)
+	batchAck(1)
+	if v, attempt, _, _ = q.DequeueAckMeta(); v != "b" || attempt != 2 {
+		t.Fatalf("DequeueAckMeta() after batch requeue = %q, %d, want b, 2", v, attempt)
+	}
+}
+
+// go test -run TestSnapshotN -v
+func TestSnapshotN(t *testing.T) {
+	q := NewNQueue[int]()
+	if ts := q.SnapshotN(3); ts != nil {
+		t.Fatalf("SnapshotN(3) on empty queue = %v, want nil", ts)
+	}
+
+	for i := 1; i <= 5; i++ {
+		q.Enqueue(i)
+	}
+
+	if ts := q.SnapshotN(3); fmt.Sprint(ts) != "[1 2 3]" {
+		t.Fatalf("SnapshotN(3) = %v, want [1 2 3]", ts)
+	}
+	if ts := q.SnapshotN(10); fmt.Sprint(ts) != "[1 2 3 4 5]" {
+		t.Fatalf("SnapshotN(10) = %v, want [1 2 3 4 5]", ts)
+	}
+	if ts := q.SnapshotN(0); ts != nil {
+		t.Fatalf("SnapshotN(0) = %v, want nil", ts)
+	}
+	if ts := q.Snapshot(); fmt.Sprint(ts) != "[1 2 3 4 5]" {
+		t.Fatalf("Snapshot() = %v, want [1 2 3 4 5]", ts)
+	}
+
+	head, ok := q.Peek()
+	if ts := q.SnapshotN(1); !ok || len(ts) != 1 || ts[0] != head {
+		t.Fatalf("SnapshotN(1) = %v, Peek() = %d, %v", ts, head, ok)
+	}
+	if q.Count() != 5 {
+		t.Fatalf("Count() = %d, want 5", q.Count())
+	}
+}
+
+// go test -run TestWithCloseOnContext -v
+func TestWithCloseOnContext(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := NewNQueue(WithCloseOnContext[int](ctx))
+	q.Enqueue(1)
+	cancel()
+
+	select {
+	case <-q.Done():
+	case <-time.After(time.Second):
+		t.Fatal("queue was not closed after context cancel")
+	}
+	if err := q.Enqueue(2); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Enqueue() after cancel = %v, want ErrQueueClosed", err)
+	}
+	if v, ok, isClose := q.DequeueWait(); !ok || !isClose || v != 1 {
+		t.Fatalf("DequeueWait() = %d, %v, %v, want 1, true, true", v, ok, isClose)
+	}
+
+	// 手动关闭的队列也要让监视 goroutine 退出。
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	for i := 0; i < 100; i++ {
+		NewNQueue(WithCloseOnContext[int](ctx2)).Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base {
+		t.Fatalf("goroutines = %d, want <= %d", n, base)
+	}
+}
+
+// go test -run TestEnqueueLen -v
+func TestEnqueueLen(t *testing.T) {
+	q := NewNQueue[int]()
+	for i := 1; i <= 3; i++ {
+		if n := q.EnqueueLen(i); n != i {
+			t.Fatalf("EnqueueLen() = %d, want %d", n, i)
+		}
+	}
+	q.Dequeue()
+	if n := q.EnqueueLen(4); n != 3 {
+		t.Fatalf("EnqueueLen() after Dequeue = %d, want 3", n)
+	}
+
+	// 并发出入队时返回值不一定单调，但插入成功时总是至少为 1。
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if n := q.EnqueueLen(j); n < 1 {
+					t.Errorf("EnqueueLen() = %d, want >= 1", n)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				q.DequeueWait()
+			}
+		}()
+	}
+	wg.Wait()
+
+	q.Close()
+	if n := q.EnqueueLen(5); n != 0 {
+		t.Fatalf("EnqueueLen() on closed queue = %d, want 0", n)
+	}
+}
+
+// go test -run TestTryEnqueue -v
+func TestTryEnqueue(t *testing.T) {
+	var q Queue[int] = NewNQueueWithCap(2, WithOverflow[int](OverflowDropOldest))
+	if q.Cap() != 2 {
+		t.Fatalf("Cap() = %d, want 2", q.Cap())
+	}
+	if !q.TryEnqueue(1) || !q.TryEnqueue(2) {
+		t.Fatal("TryEnqueue() = false with free space")
+	}
+
+	// 队列已满时立即返回 false，不按丢弃策略淘汰已有的元素。
+	if q.TryEnqueue(3) || q.Count() != 2 {
+		t.Fatalf("TryEnqueue() on full queue succeeded, Count() = %d", q.Count())
+	}
+	if v, _, _ := q.Dequeue(); v != 1 {
+		t.Fatalf("Dequeue() = %d, want 1", v)
+	}
+	if !q.TryEnqueue(3) {
+		t.Fatal("TryEnqueue() = false after Dequeue")
+	}
+
+	q.Close()
+	if q.TryEnqueue(4) {
+		t.Fatal("TryEnqueue() on closed queue = true")
+	}
+
+	// 不限容量的队列 Cap() 为 0。
+	for _, q := range []Queue[int]{NewNQueue[int](), NewOrderedNQueue[int](), NewRelaxedQueue[int](2)} {
+		if q.Cap() != 0 || !q.TryEnqueue(1) || q.Count() != 1 {
+			t.Fatalf("%T: Cap() = %d, Count() = %d, want 0, 1", q, q.Cap(), q.Count())
+		}
+	}
+}
+
+// go test -run TestEnqueueBatch -v
+func TestEnqueueBatch(t *testing.T) {
+	q := NewNQueue[int](WithLazyWakeup[int]())
+	if n, err := q.EnqueueBatch([]int{1, 2, 3, 4, 5}); n != 5 || err != nil {
+		t.Fatalf("EnqueueBatch() = %d, %v, want 5, nil", n, err)
+	}
+	if ts, isClose := q.DequeueBatch(3); !slices.Equal(ts, []int{1, 2, 3}) || isClose {
+		t.Fatalf("DequeueBatch(3) = %v, %v, want [1 2 3], false", ts, isClose)
+	}
+	if ts, _ := q.DequeueBatch(10); !slices.Equal(ts, []int{4, 5}) || q.Count() != 0 {
+		t.Fatalf("DequeueBatch(10) = %v, Count() = %d, want [4 5], 0", ts, q.Count())
+	}
+	if ts, _ := q.DequeueBatch(10); len(ts) != 0 {
+		t.Fatalf("DequeueBatch() on empty queue = %v", ts)
+	}
+
+	// 有界队列空位不足时分段插入，消费者取走元素后继续，顺序保持不变。
+	bounded := NewNQueueWithCap[int](4)
+	vs := make([]int, 100)
+	for i := range vs {
+		vs[i] = i
+	}
+	go func() {
+		bounded.EnqueueBatch(vs)
+		bounded.Close()
+	}()
+	var got []int
+	for {
+		ts, isClose, _ := bounded.DequeueBatchWait(context.Background(), 3)
+		if len(ts) > 3 || bounded.Count() > 4 {
+			t.Fatalf("DequeueBatchWait(3) = %v, Count() = %d", ts, bounded.Count())
+		}
+		got = append(got, ts...)
+		if len(ts) == 0 && isClose {
+			break
+		}
+	}
+	if !slices.Equal(got, vs) {
+		t.Fatalf("bounded batch order = %v", got)
+	}
+	if n, err := bounded.EnqueueBatch([]int{1}); n != 0 || !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("EnqueueBatch() on closed queue = %d, %v", n, err)
+	}
+
+	// 开启 WithSpill 时超出内存上限的部分写入磁盘，批量出队依次读回。
+	spilled := NewNQueue[int](WithSpill(t.TempDir(), 8, encodeInt, decodeInt))
+	spilled.EnqueueBatch(vs[:5])
+	spilled.EnqueueBatch(vs[5:])
+	if spilled.SpillLen() != 92 {
+		t.Fatalf("SpillLen() = %d, want 92", spilled.SpillLen())
+	}
+	if ts, _ := spilled.DequeueBatch(100); !slices.Equal(ts, vs) {
+		t.Fatalf("DequeueBatch() with spill = %v", ts)
+	}
+}
+
+// go test -run TestTryDequeuePeekDrain -v
+func TestTryDequeuePeekDrain(t *testing.T) {
+	persistent, err := NewPersistentQueue[int](t.TempDir(), intCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queues := []Queue[int]{NewNQueue[int](), NewOrderedNQueue[int](), NewRelaxedQueue[int](1), persistent}
+	for _, q := range queues {
+		if _, ok := q.TryDequeue(); ok {
+			t.Fatalf("%T: TryDequeue() on empty queue ok", q)
+		}
+		if ts := q.Drain(); ts != nil {
+			t.Fatalf("%T: Drain() on empty queue = %v, want nil", q, ts)
+		}
+
+		for i := 1; i <= 5; i++ {
+			q.Enqueue(i)
+		}
+		if v, ok := q.Peek(); !ok || v != 1 || q.Count() != 5 {
+			t.Fatalf("%T: Peek() = %d, %v, Count() = %d, want 1, true, 5", q, v, ok, q.Count())
+		}
+		if v, ok := q.TryDequeue(); !ok || v != 1 {
+			t.Fatalf("%T: TryDequeue() = %d, %v, want 1, true", q, v, ok)
+		}
+
+		// Drain 一次取出剩余的所有元素，关闭后仍可使用。
+		q.Close()
+		if ts := q.Drain(); !slices.Equal(ts, []int{2, 3, 4, 5}) || q.Count() != 0 {
+			t.Fatalf("%T: Drain() = %v, Count() = %d, want [2 3 4 5], 0", q, ts, q.Count())
+		}
+		if _, ok, isClose := q.DequeueWait(); ok || !isClose {
+			t.Fatalf("%T: DequeueWait() after Drain = %v, %v, want false, true", q, ok, isClose)
+		}
+	}
+}
+
+// go test -run TestNonComparable -v
+// 队列内部不能对 T 做任何比较，否则以下不可比较的类型将无法实例化。
+func TestNonComparable(t *testing.T) {
+	bq := NewNQueue[[]byte]()
+	bq.Enqueue([]byte("a"))
+	bq.Enqueue(nil)
+	if v, ok := bq.Peek(); !ok || string(v) != "a" {
+		t.Fatalf("Peek() = %q, %v, want \"a\", true", v, ok)
+	}
+	if ts := bq.Snapshot(); len(ts) != 2 {
+		t.Fatalf("Snapshot() = %q, want 2 items", ts)
+	}
+	if v, ok, _ := bq.DequeueWait(); !ok || string(v) != "a" {
+		t.Fatalf("DequeueWait() = %q, %v, want \"a\", true", v, ok)
+	}
+	if v, ok, _ := bq.Dequeue(); !ok || v != nil {
+		t.Fatalf("Dequeue() = %q, %v, want nil, true", v, ok)
+	}
+
+	mq := NewNQueue[map[string]int]()
+	mq.Enqueue(map[string]int{"a": 1})
+	v, ack, ok := mq.DequeueAck()
+	if !ok || v["a"] != 1 {
+		t.Fatalf("DequeueAck() = %v, %v, want map[a:1], true", v, ok)
+	}
+	ack(true)
+	c := mq.Cursor()
+	if v, ok, _ := c.Next(); !ok || v["a"] != 1 {
+		t.Fatalf("Next() = %v, %v, want map[a:1], true", v, ok)
+	}
+	mq.Close()
+	if _, ok, isClose := c.Next(); ok || !isClose {
+		t.Fatalf("Next() on closed queue = %v, %v, want false, true", ok, isClose)
+	}
+}
+
+// go test -run TestWithOnEmpty -v
+func TestWithOnEmpty(t *testing.T) {
+	var fired atomic.Int64
+	q := NewNQueue(WithOnEmpty[int](func() { fired.Add(1) }))
+
+	const cycles = 1000
+	for i := 0; i < cycles; i++ {
+		for j := 0; j < i%5+1; j++ {
+			q.Enqueue(j)
+		}
+		for {
+			if _, ok, _ := q.Dequeue(); !ok {
+				break
+			}
+		}
+		q.Dequeue() // 保持为空时不会再次触发。
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for fired.Load() < cycles && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := fired.Load(); n != cycles {
+		t.Fatalf("onEmpty fired %d times, want %d", n, cycles)
+	}
+}
+
+// go test -run TestWithLazyWakeup -v
+func TestWithLazyWakeup(t *testing.T) {
+	q := NewNQueue(WithLazyWakeup[int]())
+
+	const producers, consumers, perProducer = 8, 4, 10000
+	var sum atomic.Int64
+	var wg, wg1 sync.WaitGroup
+	wg1.Add(consumers)
+	for i := 0; i < consumers; i++ {
+		go func() {
+			defer wg1.Done()
+			for {
+				v, ok, _ := q.DequeueWait()
+				if !ok {
+					return
+				}
+				sum.Add(int64(v))
+			}
+		}()
+	}
+
+	wg.Add(producers)
+	for i := 0; i < producers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 1; j <= perProducer; j++ {
+				q.Enqueue(j)
+				if j%100 == 0 {
+					time.Sleep(time.Microsecond) // 让消费者有机会阻塞。
+				}
+			}
+		}()
+	}
+
+	// 消费者不能在队列中还有元素时一直阻塞。
+	wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitDrain(ctx); err != nil {
+		t.Fatalf("WaitDrain() = %v, %d items left", err, q.Count())
+	}
+	q.Close()
+	wg1.Wait()
+
+	if want := int64(producers * perProducer * (perProducer + 1) / 2); sum.Load() != want {
+		t.Fatalf("sum = %d, want %d", sum.Load(), want)
+	}
+}
+
+// go test -run none -bench BenchmarkWakeup -benchmem
+func BenchmarkWakeup(b *testing.B) {
+	run := func(b *testing.B, q *NQueue[int]) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if _, ok, _ := q.DequeueWait(); !ok {
+					return
+				}
+			}
+		}()
+
+		for i := 0; i < b.N; i++ {
+			q.Enqueue(i)
+		}
+		q.Close()
+		<-done
+		b.ReportMetric(float64(q.wakeups)/float64(b.N), "wakeups/op")
+	}
+
+	b.Run("Default", func(b *testing.B) { run(b, NewNQueue[int]()) })
+	b.Run("LazyWakeup", func(b *testing.B) { run(b, NewNQueue(WithLazyWakeup[int]())) })
+}
+
+// go test -run TestByteLen -v
+func TestByteLen(t *testing.T) {
+	q := NewNQueue(WithSizeOf(func(b []byte) int { return len(b) }))
+	for _, s := range []string{"a", "bcd", "", "efghij"} {
+		q.Enqueue([]byte(s))
+	}
+	if n := q.ByteLen(); n != 10 {
+		t.Fatalf("ByteLen() = %d, want 10", n)
+	}
+
+	q.Dequeue()
+	if n := q.ByteLen(); n != 9 {
+		t.Fatalf("ByteLen() after Dequeue = %d, want 9", n)
+	}
+
+	v, ack, _ := q.DequeueAck()
+	if n := q.ByteLen(); n != 6 {
+		t.Fatalf("ByteLen() after DequeueAck(%q) = %d, want 6", v, n)
+	}
+	ack(true)
+	if n := q.ByteLen(); n != 9 {
+		t.Fatalf("ByteLen() after requeue = %d, want 9", n)
+	}
+
+	other := NewNQueue(WithSizeOf(func(b []byte) int { return len(b) }))
+	Transfer[[]byte](other, q, 2)
+	if q.ByteLen() != 6 || other.ByteLen() != 3 {
+		t.Fatalf("ByteLen() after Transfer = %d, %d, want 6, 3", q.ByteLen(), other.ByteLen())
+	}
+
+	q.DequeueBatchWait(context.Background(), 10)
+	if n := q.ByteLen(); n != 0 {
+		t.Fatalf("ByteLen() after drain = %d, want 0", n)
+	}
+
+	if n := NewNQueue[[]byte]().ByteLen(); n != 0 {
+		t.Fatalf("ByteLen() without WithSizeOf = %d, want 0", n)
+	}
+}
+
+// go test -run TestDequeueFuncDrainOnClose -v
+func TestDequeueFuncDrainOnClose(t *testing.T) {
+	for round := 0; round < 100; round++ {
+		q := NewNQueue[int]()
+		const n = 1000
+
+		var seen int
+		errc := make(chan error, 1)
+		go func() {
+			errc <- q.DequeueFunc(func(v int, isClose bool) bool {
+				if v != seen {
+					t.Errorf("callback got %d, want %d", v, seen)
+				}
+				seen++
+				return true
+			})
+		}()
+
+		for i := 0; i < n; i++ {
+			q.Enqueue(i)
+		}
+		q.Close()
+
+		if err := <-errc; !errors.Is(err, ErrQueueClosedEmpty) {
+			t.Fatalf("DequeueFunc() = %v, want ErrQueueClosedEmpty", err)
+		}
+		if seen != n {
+			t.Fatalf("round %d: callback observed %d items, want %d", round, seen, n)
+		}
+	}
+}
+
+// go test -run TestReadWriteOnly -v
+func TestReadWriteOnly(t *testing.T) {
+	q := NewNQueue[int]()
+	w, r := q.WriteOnly(), q.ReadOnly()
+
+	if _, ok := w.(Queue[int]); ok {
+		t.Fatal("WriteOnly() can be asserted back to Queue")
+	}
+	if _, ok := r.(Queue[int]); ok {
+		t.Fatal("ReadOnly() can be asserted back to Queue")
+	}
+
+	w.Enqueue(1)
+	if w.Count() != 1 || r.Count() != 1 {
+		t.Fatalf("Count() = %d, %d, want 1, 1", w.Count(), r.Count())
+	}
+	if v, ok, _ := r.DequeueWait(); !ok || v != 1 {
+		t.Fatalf("DequeueWait() = %d, %v, want 1, true", v, ok)
+	}
+
+	q.Close()
+	if !w.IsClosed() || !r.IsClosed() {
+		t.Fatal("IsClosed() = false after Close")
+	}
+	if err := r.DequeueFunc(func(int, bool) bool { return true }); !errors.Is(err, ErrQueueClosedEmpty) {
+		t.Fatalf("DequeueFunc() = %v, want ErrQueueClosedEmpty", err)
+	}
+}
+
+// go test -run TestDequeueWaitNoSpuriousReturn -v
+func TestDequeueWaitNoSpuriousReturn(t *testing.T) {
+	q := NewNQueue[int]()
+
+	const producers, consumers, perProducer = 4, 16, 20000
+	var spurious, received atomic.Int64
+	var wg, wg1 sync.WaitGroup
+
+	wg1.Add(consumers)
+	for i := 0; i < consumers; i++ {
+		go func() {
+			defer wg1.Done()
+			for {
+				_, ok, isClose := q.DequeueWait()
+				if ok {
+					received.Add(1)
+					continue
+				}
+				if !isClose {
+					spurious.Add(1)
+					continue
+				}
+				return
+			}
+		}()
+	}
+
+	wg.Add(producers)
+	for i := 0; i < producers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perProducer; j++ {
+				q.Enqueue(j)
+				if j%64 == 0 {
+					runtime.Gosched() // 制造队列反复变空的情况，让消费者频繁阻塞和被唤醒。
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	q.Close()
+	wg1.Wait()
+
+	if n := spurious.Load(); n != 0 {
+		t.Fatalf("DequeueWait returned (zero, false, false) %d times", n)
+	}
+	if n := received.Load(); n != producers*perProducer {
+		t.Fatalf("received %d items, want %d", n, producers*perProducer)
+	}
+}
+
+// go test -run none -bench BenchmarkHotPath -benchmem
+// 对比不开启任何选项的队列与开启全部可选功能的队列，展示可选功能在入队/出队热路径上的开销。
+func BenchmarkHotPath(b *testing.B) {
+	run := func(b *testing.B, q *NQueue[int]) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q.Enqueue(i)
+			q.DequeueWait()
+		}
+		q.Close()
+	}
+
+	b.Run("Bare", func(b *testing.B) {
+		run(b, NewNQueue[int]())
+	})
+
+	b.Run("AllFeatures", func(b *testing.B) {
+		run(b, NewNQueue(
+			WithTimestamps[int](),
+			WithSizeOf(func(int) int { return 8 }),
+			WithOnEmpty[int](func() {}),
+			WithStuckWatchdog[int](time.Hour, func(time.Duration) {}),
+		))
+	})
+}
+
+// go test -run TestWithSoftLimit -v
+func TestWithSoftLimit(t *testing.T) {
+	var fired atomic.Int64
+	curs := make(chan int, 10)
+	q := NewNQueue(WithSoftLimit[int](10, func(cur int) {
+		fired.Add(1)
+		curs <- cur
+	}))
+
+	wait := func(want int64) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for fired.Load() < want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if n := fired.Load(); n != want {
+			t.Fatalf("onExceed fired %d times, want %d", n, want)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+	wait(0) // 未超过软上限。
+
+	q.Enqueue(10)
+	wait(1)
+	if cur := <-curs; cur != 11 {
+		t.Fatalf("onExceed(%d), want 11", cur)
+	}
+
+	// 在上限附近波动不会重复触发。
+	for i := 0; i < 5; i++ {
+		q.Dequeue()
+		q.Dequeue()
+		q.Enqueue(1)
+		q.Enqueue(1)
+	}
+	for i := 0; i < 5; i++ {
+		q.Dequeue()
+	}
+	q.Enqueue(1)
+	q.Enqueue(1)
+	q.Enqueue(1)
+	q.Enqueue(1)
+	q.Enqueue(1)
+	wait(1)
+
+	// 回落到一半以下后重新触发。
+	for q.Count() > 5 {
+		q.Dequeue()
+	}
+	for i := 0; i < 6; i++ {
+		q.Enqueue(1)
+	}
+	wait(2)
+	if q.Count() != 11 {
+		t.Fatalf("Count() = %d, want 11", q.Count())
+	}
+
+	// 回调为 nil 时选项没有作用，越过软上限也不会出错。
+	q = NewNQueue(WithSoftLimit[int](1, nil))
+	q.Enqueue(1)
+	q.Enqueue(2)
+	time.Sleep(10 * time.Millisecond)
+}
+
+// go test -run TestEnqueueTagged -v
+func TestEnqueueTagged(t *testing.T) {
+	q := NewNQueue[int]()
+
+	const producers, perProducer = 4, 1000
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func(tag string) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.EnqueueTagged(tag, i)
+			}
+		}(fmt.Sprintf("p%d", p))
+	}
+	q.Enqueue(-1) // 不带标签的元素。
+
+	go func() {
+		wg.Wait()
+		q.Close()
+	}()
+
+	served := map[string]int{}
+	last := map[string]int{}
+	for {
+		v, tag, ok, _ := q.DequeueTagged()
+		if !ok {
+			break
+		}
+		if tag != "" {
+			if prev, seen := last[tag]; seen && v != prev+1 {
+				t.Fatalf("producer %s: got %d after %d", tag, v, prev)
+			}
+			last[tag] = v
+		}
+		served[tag]++
+	}
+
+	if served[""] != 1 {
+		t.Fatalf("served %d untagged items, want 1", served[""])
+	}
+	for p := 0; p < producers; p++ {
+		if n := served[fmt.Sprintf("p%d", p)]; n != perProducer {
+			t.Fatalf("served %d items from p%d, want %d", n, p, perProducer)
+		}
+	}
+}
+
+// go test -run TestConsistentSnapshot -v
+func TestConsistentSnapshot(t *testing.T) {
+	q := NewNQueue[int]()
+
+	// 单个生产者按顺序入队，单个消费者按顺序出队，任意时刻队列的内容都是一段连续递增的整数。
+	const n = 200000
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			q.Enqueue(i)
+		}
+		q.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			if _, ok, _ := q.DequeueWait(); !ok {
+				return
+			}
+		}
+	}()
+
+	for snapshots := 0; !q.IsClosed() || q.Count() > 0; snapshots++ {
+		ts := q.ConsistentSnapshot()
+		for i := 1; i < len(ts); i++ {
+			if ts[i] != ts[i-1]+1 {
+				t.Fatalf("snapshot %d is not contiguous at %d: %d after %d", snapshots, i, ts[i], ts[i-1])
+			}
+		}
+	}
+	wg.Wait()
+}
+
+// go test -run TestNodeMeta -v
+func TestNodeMeta(t *testing.T) {
+	// 元数据放在单独分配的结构体中，不使用可选功能时节点只有值、next 和一个 nil 指针。
+	if size, want := unsafe.Sizeof(node[int]{}), 3*unsafe.Sizeof(uintptr(0)); size != want {
+		t.Fatalf("node[int] size = %d, want %d", size, want)
+	}
+
+	q := NewNQueue[int]()
+	q.Enqueue(1)
+	if q.head.meta != nil {
+		t.Fatal("plain Enqueue allocated node metadata")
+	}
+	q.EnqueueTagged("p", 2)
+	v, _, ack, _ := q.DequeueAckMeta()
+	ack(true)
+	if v != 1 || q.head.meta == nil || q.head.meta.attempts != 1 {
+		t.Fatalf("requeued node %d, meta = %+v, want 1 with attempts 1", v, q.head.meta)
+	}
+
+	// 回收的节点清零元数据，复用时不会带上旧的标签或投递次数。
+	q.Drain()
+	q.Enqueue(3)
+	if _, tag, _, _ := q.DequeueTagged(); tag != "" {
+		t.Fatalf("reused node tag = %q, want empty", tag)
+	}
+}
+
+// go test -run TestNilElements -v
+func TestNilElements(t *testing.T) {
+	// 指针和接口类型的 nil 是合法元素，必须以 ok 为 true 出队，不能与关闭信号混淆。
+	check := func(t *testing.T, q Queue[error], name string) {
+		for i := 0; i < 3; i++ {
+			if err := q.Enqueue(nil); err != nil {
+				t.Fatalf("%s: Enqueue(nil) = %v", name, err)
+			}
+		}
+
+		if v, ok, isClose := q.Dequeue(); v != nil || !ok || isClose {
+			t.Fatalf("%s: Dequeue() = %v, %v, %v, want nil, true, false", name, v, ok, isClose)
+		}
+		q.Close()
+
+		// 关闭后剩余的 nil 元素仍然以 ok 为 true 出队，isClose 同时为 true。
+		for i := 0; i < 2; i++ {
+			if v, ok, isClose := q.DequeueWait(); v != nil || !ok || !isClose {
+				t.Fatalf("%s: DequeueWait() = %v, %v, %v, want nil, true, true", name, v, ok, isClose)
+			}
+		}
+		if _, ok, isClose := q.DequeueWait(); ok || !isClose {
+			t.Fatalf("%s: DequeueWait() on drained queue = %v, %v, want false, true", name, ok, isClose)
+		}
+	}
+
+	check(t, NewNQueue[error](), "NQueue")
+	check(t, NewPriorityQueue(func(a, b error) bool { return false }), "PriorityQueue")
+
+	q := NewNQueue[*int]()
+	q.Enqueue(nil)
+	one := 1
+	q.Enqueue(&one)
+	q.Close()
+
+	var got []*int
+	err := q.DequeueFunc(func(v *int, isClose bool) bool {
+		got = append(got, v)
+		return true
+	})
+	if !errors.Is(err, ErrQueueClosedEmpty) || len(got) != 2 || got[0] != nil || got[1] != &one {
+		t.Fatalf("DequeueFunc() = %v, got %v, want ErrQueueClosedEmpty, [nil %p]", err, got, &one)
+	}
+
+	// Chan 同样会投递 nil 元素。
+	q = NewNQueue[*int]()
+	q.Enqueue(nil)
+	q.Close()
+	n := 0
+	for v := range q.Chan() {
+		if v != nil {
+			t.Fatalf("Chan() delivered %v, want nil", v)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("Chan() delivered %d values, want 1", n)
+	}
+}
+
+// go test -run TestDrained -v
+func TestDrained(t *testing.T) {
+	q := NewNQueue[int]()
+	q.Enqueue(1)
+	q.Enqueue(2)
+	q.Close()
+
+	// 关闭时仍有元素，Done 已关闭而 Drained 尚未关闭。
+	select {
+	case <-q.Done():
+	default:
+		t.Fatal("Done() not closed after Close")
+	}
+	if q.IsDrained() {
+		t.Fatal("IsDrained() = true with pending items")
+	}
+
+	q.Dequeue()
+	_, ack, _ := q.DequeueAck()
+	if q.IsDrained() {
+		t.Fatal("IsDrained() = true with an unacknowledged item")
+	}
+
+	// 未确认的元素重新放回后仍需出队。
+	ack(true)
+	if q.IsDrained() {
+		t.Fatal("IsDrained() = true after requeue")
+	}
+	go q.Dequeue()
+	select {
+	case <-q.Drained():
+	case <-time.After(time.Second):
+		t.Fatal("Drained() not closed after the last item was dequeued")
+	}
+	if !q.IsDrained() {
+		t.Fatal("IsDrained() = false after Drained() was closed")
+	}
+
+	// 关闭时已经为空的队列立即进入终止状态。
+	empty := NewNQueue[int]()
+	empty.Close()
+	if !empty.IsDrained() {
+		t.Fatal("IsDrained() = false for a queue closed while empty")
+	}
+}
+
+// go test -run TestCloseAndDrain -v
+func TestCloseAndDrain(t *testing.T) {
+	q := NewNQueue[int]()
+	for i := 0; i < 3; i++ {
+		q.Enqueue(i)
+	}
+
+	// 没有消费者时 ctx 结束返回 ctx.Err()，队列保持关闭，剩余元素仍可出队。
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.CloseAndDrain(ctx); !errors.Is(err, context.DeadlineExceeded) || !q.IsClosed() {
+		t.Fatalf("CloseAndDrain() = %v, IsClosed() = %v, want DeadlineExceeded, true", err, q.IsClosed())
+	}
+
+	go func() {
+		for {
+			if _, ok, _ := q.DequeueWait(); !ok {
+				return
+			}
+		}
+	}()
+	if err := q.CloseAndDrain(context.Background()); err != nil || !q.IsDrained() {
+		t.Fatalf("CloseAndDrain() with a consumer = %v, IsDrained() = %v, want nil, true", err, q.IsDrained())
+	}
+}
+
+// go test -run TestCloseAndDiscard -v
+func TestCloseAndDiscard(t *testing.T) {
+	dlq := NewNQueue[int]()
+	var reasons []DropReason
+	q := NewNQueue(WithDeadLetter[int](dlq), WithOnDrop(func(_ int, r DropReason) { reasons = append(reasons, r) }))
+	for i := 0; i < 5; i++ {
+		q.Enqueue(i)
+	}
+	q.EnqueueAfter(5, time.Hour)
+	_, ack, _ := q.DequeueAck()
+
+	// 待出队和延迟的元素都被丢弃并以 DropDiscarded 报告，不转发到死信队列；未确认的元素不受影响。
+	if n := q.CloseAndDiscard(); n != 5 {
+		t.Fatalf("CloseAndDiscard() = %d, want 5", n)
+	}
+	if len(reasons) != 5 || reasons[0] != DropDiscarded || dlq.Count() != 0 || q.Count() != 0 {
+		t.Fatalf("reasons = %v, dlq.Count() = %d, Count() = %d, want 5 x discarded, 0, 0", reasons, dlq.Count(), q.Count())
+	}
+	if q.IsDrained() {
+		t.Fatal("IsDrained() = true with an unacknowledged item")
+	}
+	ack(false)
+	if !q.IsDrained() {
+		t.Fatal("IsDrained() = false after the last ack")
+	}
+	if n := q.CloseAndDiscard(); n != 0 {
+		t.Fatalf("CloseAndDiscard() on a drained queue = %d, want 0", n)
+	}
+}
+
+// go test -run TestReset -v
+func TestReset(t *testing.T) {
+	q := NewNQueueWithCap(2, WithTimestamps[int]())
+	if err := q.Reset(); !errors.Is(err, ErrQueueInUse) {
+		t.Fatalf("Reset() on an open queue = %v, want ErrQueueInUse", err)
+	}
+	q.Enqueue(1)
+	q.Enqueue(2)
+	_, ack, _ := q.DequeueAck()
+	q.Close()
+	if err := q.Reset(); !errors.Is(err, ErrQueueInUse) {
+		t.Fatalf("Reset() with an unacknowledged item = %v, want ErrQueueInUse", err)
+	}
+	ack(false)
+
+	// 复位后队列重新打开：剩余元素被丢弃，统计清零，容量等配置保持不变，Done 和 Drained 是新的通道。
+	if err := q.Reset(); err != nil {
+		t.Fatalf("Reset() = %v", err)
+	}
+	if q.IsClosed() || q.IsDrained() || q.Count() != 0 || q.Cap() != 2 {
+		t.Fatalf("after Reset: IsClosed() = %v, IsDrained() = %v, Count() = %d, Cap() = %d", q.IsClosed(), q.IsDrained(), q.Count(), q.Cap())
+	}
+	if s := q.Stats(); s.Enqueued != 0 || s.Dequeued != 0 || s.Dropped != 0 {
+		t.Fatalf("Stats() after Reset = %+v, want zero counters", s)
+	}
+	select {
+	case <-q.Done():
+		t.Fatal("Done() closed after Reset")
+	default:
+	}
+	q.Enqueue(3)
+	q.Enqueue(4)
+	if q.TryEnqueue(5) {
+		t.Fatal("TryEnqueue() succeeded on a full queue after Reset")
+	}
+	if v, ok, _ := q.Dequeue(); !ok || v != 3 {
+		t.Fatalf("Dequeue() after Reset = %d, %v, want 3, true", v, ok)
+	}
+
+	// 重新启动的监视 goroutine 绑定新的 done：context 结束时再次关闭队列。
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewNQueue(WithCloseOnContext[int](ctx))
+	w.Close()
+	w.Reset()
+	cancel()
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		t.Fatal("queue not closed by its context after Reset")
+	}
+}
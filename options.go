@@ -0,0 +1,149 @@
+package nqueue
+
+import (
+	"context"
+	"time"
+)
+
+// Option 是 NewNQueue 的可选配置项。
+type Option[T any] func(q *NQueue[T])
+
+// WithCloseOnContext 选项让队列在 ctx 结束时自动关闭，从而把队列的生命周期绑定到请求或服务的 context 上。
+// 队列内部会启动一个监视 goroutine，在 ctx 结束或队列被手动关闭后退出。
+func WithCloseOnContext[T any](ctx context.Context) Option[T] {
+	return func(q *NQueue[T]) {
+		q.closeCtx = ctx
+	}
+}
+
+// WithOnEmpty 选项设置一个回调，每当队列中的元素数量从正数变为 0 时在新的 goroutine 中调用一次。
+// 队列保持为空时不会重复调用，适合在队列空闲时释放关联的资源。
+func WithOnEmpty[T any](fn func()) Option[T] {
+	return func(q *NQueue[T]) {
+		q.onEmpty = fn
+	}
+}
+
+// WithLazyWakeup 选项减少入队时的唤醒操作：没有消费者阻塞等待时（例如单个消费者一直在快速取元素）
+// 入队不发出唤醒，有消费者阻塞时只唤醒其中一个，而不是广播唤醒所有消费者。
+func WithLazyWakeup[T any]() Option[T] {
+	return func(q *NQueue[T]) {
+		q.lazyWake = true
+	}
+}
+
+// WithSizeOf 选项设置元素大小的计算函数，用于维护 ByteLen 返回的总字节数。
+// 例如对于 []byte 类型的队列，可以传入 func(b []byte) int { return len(b) }。
+func WithSizeOf[T any](sizeOf func(T) int) Option[T] {
+	return func(q *NQueue[T]) {
+		q.sizeOf = sizeOf
+	}
+}
+
+// WithTimestamps 选项让队列在入队时记录每个元素的入队时间，用于 OldestAge 等依赖元素等待时间的功能。
+// 未开启时入队不会读取时钟。
+func WithTimestamps[T any]() Option[T] {
+	return func(q *NQueue[T]) {
+		q.stamped = true
+	}
+}
+
+// WithStuckWatchdog 选项开启消费者卡死检测：队列内部的监视 goroutine 定期检查头部元素的等待时间，
+// 超过 d 时调用 onStuck 并传入当前的等待时间，用于发现卡死或已退出的消费者。
+// 同一次滞留只会报告一次，头部元素被取走、等待时间回落到 d 以下后重新开始检测。
+// 该选项会自动开启 WithTimestamps，监视 goroutine 在队列关闭后退出。
+func WithStuckWatchdog[T any](d time.Duration, onStuck func(oldestAge time.Duration)) Option[T] {
+	return func(q *NQueue[T]) {
+		q.stamped = true
+		q.stuckAge = d
+		q.onStuck = onStuck
+	}
+}
+
+// WithSoftLimit 选项设置一个软上限：元素数量超过 n 时在新的 goroutine 中调用 onExceed 并传入当前数量，
+// 用于在内存耗尽之前发现失控的生产者。软上限不会阻塞入队，也不会丢弃元素。
+// 每次向上越过 n 只触发一次，元素数量回落到 n/2 以下后才会重新触发，避免在上限附近反复报告。
+// onExceed 为 nil 时该选项没有作用。
+func WithSoftLimit[T any](n int, onExceed func(cur int)) Option[T] {
+	return func(q *NQueue[T]) {
+		if onExceed == nil {
+			return
+		}
+		q.softLimit = int64(max(n, 0))
+		q.onExceed = onExceed
+	}
+}
+
+// WithSpill 选项让队列在内存中最多保留 memLimit 个元素，超出的元素用 encode 序列化后写入 dir 目录下的临时文件，
+// 内存中的元素被取走后再用 decode 按顺序读回，适合积压量可能远超内存的缓冲场景。memLimit 小于 1 时按 1 处理。
+//
+// 溢出的元素计入 Count() 和容量上限，出队顺序与不开启该选项时完全相同。磁盘读写在持有队列锁时进行，
+// 溢出期间入队和出队的延迟会明显增加。写入失败时入队返回相应的错误，元素不会入队；
+// 读回失败的元素会被丢弃，错误可以通过 SpillErr 获得。溢出的元素不保留 EnqueueTagged 设置的标签。
+//
+// 溢出文件只是内存的延伸，不用于持久化：进程退出后文件中的元素无法恢复。队列关闭并且磁盘上的元素被全部取走后，
+// 溢出文件会被删除。
+func WithSpill[T any](dir string, memLimit int, encode func(T) ([]byte, error), decode func([]byte) (T, error)) Option[T] {
+	return func(q *NQueue[T]) {
+		q.spill = &spill[T]{
+			dir:      dir,
+			memLimit: int64(max(memLimit, 1)),
+			encode:   encode,
+			decode:   decode,
+		}
+	}
+}
+
+// WithOverflow 选项设置有界队列已满时的入队策略，默认为 OverflowBlock。
+// 丢弃策略下入队不会阻塞，被丢弃的元素不会返回错误，可以通过 WithOnDrop 观察。对不限容量的队列没有作用。
+func WithOverflow[T any](policy OverflowPolicy) Option[T] {
+	return func(q *NQueue[T]) {
+		q.onFull = policy
+	}
+}
+
+// WithOnDrop 选项设置一个回调，队列丢弃的每一个元素都会以丢弃原因调用一次，参见 DropReason。
+// 所有丢弃路径都经过这个回调，适合集中统计丢失的元素或把它们转发到其他队列。
+// 回调在持有队列锁时同步调用，调用顺序与丢弃顺序一致；回调中不能调用该队列的方法。
+func WithOnDrop[T any](fn func(v T, reason DropReason)) Option[T] {
+	return func(q *NQueue[T]) {
+		q.onDrop = fn
+	}
+}
+
+// WithDeadLetter 选项设置一个死信队列：队列丢弃的每一个元素（参见 DropReason）都会被转发到 dlq，而不是直接丢失。
+// 转发使用不阻塞的入队，dlq 已满或已关闭时元素最终丢失，以 DropDeadLetterFailed 报告并计入 Stats 的 DLQFailed，
+// 因此一个卡住的死信队列不会拖住这个队列。无法读回的溢出元素只有零值，CloseAndDiscard 主动丢弃的元素是有意放弃的，都不会被转发。
+// 与 WithMaxAttempts 一起使用时，多次处理失败的元素会自动转入 dlq。
+//
+// 转发在持有这个队列的锁时进行：dlq 不能把元素再转发回这个队列，dlq 就是这个队列本身时每次转发都按失败处理。
+func WithDeadLetter[T any](dlq Queue[T]) Option[T] {
+	return func(q *NQueue[T]) {
+		q.dlq = dlq
+	}
+}
+
+// WithMaxAttempts 选项设置每个元素最多被投递的次数：通过 DequeueAck、DequeueAckMeta 或 DequeueBatchAck
+// 取出的元素在第 n 次投递后仍被要求重新投递时不再放回队列，而是以 DropMaxAttempts 丢弃，
+// 设置了 WithDeadLetter 时转发到死信队列。n 小于等于 0 时不限制投递次数，这是默认行为。
+func WithMaxAttempts[T any](n int) Option[T] {
+	return func(q *NQueue[T]) {
+		q.maxTries = int32(max(n, 0))
+	}
+}
+
+// WithRequeuePolicy 选项设置确认回调要求重新投递的元素放回队列的位置，默认为 RequeueHead：
+// RequeueHead 立即重试，RequeueTail 排在已入队的元素之后，RequeueDelay 在一段时间之后才重新可见。
+// 同一批放回的元素总是保持原来的相对顺序。
+func WithRequeuePolicy[T any](policy RequeuePolicy) Option[T] {
+	return func(q *NQueue[T]) {
+		q.requeueAt = policy
+	}
+}
+
+// WithHooks 选项设置观测回调，用于把队列接入 Prometheus、OpenTelemetry 等监控系统，参见 Hooks。
+func WithHooks[T any](h Hooks) Option[T] {
+	return func(q *NQueue[T]) {
+		q.hooks = h
+	}
+}
@@ -0,0 +1,517 @@
+package nqueue
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrWALCorrupted 表示 NewPersistentQueue 恢复时发现预写日志中的记录已损坏（校验失败或不在最后一个段的末尾却不完整），
+// 无法确定之后的数据是否可信。只有最后一个段末尾声明的长度超出文件末尾的记录（例如写入时进程崩溃）不算损坏，会被截断。
+var ErrWALCorrupted = errors.New("queue write-ahead log is corrupted")
+
+// Codec 是元素的序列化方式，PersistentQueue 用它把元素写入预写日志并在恢复时读回。
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// SyncPolicy 决定 PersistentQueue 何时把预写日志刷到磁盘（fsync），由 WithSyncPolicy 设置，默认为 SyncNever。
+type SyncPolicy struct {
+	always   bool
+	interval time.Duration
+}
+
+var (
+	// SyncNever 表示不主动刷盘：每条记录写入后交给操作系统，进程崩溃不会丢失数据，但机器断电可能丢失最近的写入。
+	SyncNever = SyncPolicy{}
+	// SyncAlways 表示每条记录写入后立即刷盘，最安全但每次入队和出队都要等待磁盘。
+	SyncAlways = SyncPolicy{always: true}
+)
+
+// SyncEvery 函数返回一个定期刷盘的策略：后台 goroutine 每隔 d 刷盘一次，断电最多丢失最近 d 内的写入。
+// d 小于等于 0 时等同于 SyncNever。
+func SyncEvery(d time.Duration) SyncPolicy {
+	if d <= 0 {
+		return SyncNever
+	}
+	return SyncPolicy{interval: d}
+}
+
+// persistentConfig 是 NewPersistentQueue 的配置。
+type persistentConfig struct {
+	sync        SyncPolicy // 刷盘策略。
+	segmentSize int64      // 单个段文件的大小上限。
+}
+
+// PersistentOption 是 NewPersistentQueue 的可选配置项。
+type PersistentOption func(c *persistentConfig)
+
+// WithSyncPolicy 选项设置预写日志的刷盘策略，参见 SyncPolicy。
+func WithSyncPolicy(p SyncPolicy) PersistentOption {
+	return func(c *persistentConfig) {
+		c.sync = p
+	}
+}
+
+// WithSegmentSize 选项设置单个段文件的大小上限，默认为 64 MiB。段文件写满后创建新的段，
+// 所有元素都已出队的旧段会被删除，因此磁盘占用大致与未出队的元素数量成正比。
+func WithSegmentSize(n int64) PersistentOption {
+	return func(c *persistentConfig) {
+		if n > 0 {
+			c.segmentSize = n
+		}
+	}
+}
+
+const (
+	walHeader  = 9 // 每条记录的头部：4 字节的数据长度、4 字节的 CRC32 校验和与 1 字节的记录类型。
+	walEnqueue = 'E'
+	walAck     = 'A'
+)
+
+// walSegment 是预写日志的一个段文件。
+type walSegment struct {
+	path string
+	last uint64 // 段中最后一个入队记录的序号，0 表示段中没有入队记录。
+}
+
+// PersistentQueue 是一个由磁盘上的预写日志（WAL）保护的队列，实现了 Queue 接口，进程崩溃或重启后未出队的元素不会丢失。
+// 元素同时保存在内存中的 NQueue 里，入队和出队的阻塞与关闭语义都与 NQueue 相同；
+// 磁盘只用于恢复：每次入队追加一条入队记录，每次出队追加一条确认记录，重启时重放日志得到尚未出队的元素。
+//
+// 元素在出队时即被确认：出队之后、确认记录写入之前的崩溃会让这个元素在重启后再次出队（至少一次），
+// 出队后处理失败的元素不会自动恢复，需要时由调用方重新入队。日志按段存储，所有元素都已出队的段会被删除。
+// 同一个目录同时只能被一个 PersistentQueue 使用。
+type PersistentQueue[T any] struct {
+	q        *NQueue[T]       // 保存未出队元素的内存队列。
+	codec    Codec[T]         // 元素的序列化方式。
+	config   persistentConfig // 配置。
+	dir      string           // 段文件所在的目录。
+	lock     sync.Mutex       // 保护以下字段和日志写入。
+	file     *os.File         // 当前追加写入的段文件，队列关闭且取空后为 nil。
+	size     int64            // 当前段文件的大小。
+	segments []walSegment     // 按顺序排列的段文件，最后一个是当前段。
+	seq      uint64           // 最后一个入队记录的序号。
+	acked    uint64           // 已确认出队的最大序号，序号不大于它的元素都已出队。
+	err      error            // 写入确认记录时遇到的第一个错误。
+	buf      []byte           // 编码记录的缓冲区。
+	stop     chan struct{}    // 段文件关闭时被关闭的通道，用于结束定期刷盘的 goroutine。
+}
+
+var _ Queue[int] = (*PersistentQueue[int])(nil)
+
+// NewPersistentQueue 函数在目录 dir 中打开或创建一个持久化队列，重放已有的预写日志并恢复尚未出队的元素。
+// 日志中的记录损坏时返回 ErrWALCorrupted；最后一个段末尾不完整的记录会被截断。
+func NewPersistentQueue[T any](dir string, codec Codec[T], opts ...PersistentOption) (*PersistentQueue[T], error) {
+	p := &PersistentQueue[T]{
+		q:      NewNQueue[T](),
+		codec:  codec,
+		config: persistentConfig{segmentSize: 64 << 20},
+		dir:    dir,
+		stop:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&p.config)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := p.recover(); err != nil {
+		return nil, err
+	}
+
+	if p.config.sync.interval > 0 {
+		go p.syncEvery(p.config.sync.interval)
+	}
+	return p, nil
+}
+
+// recover 方法按顺序重放 dir 中的所有段文件，把尚未确认的元素放入内存队列，并打开最后一个段继续写入。
+func (p *PersistentQueue[T]) recover() error {
+	paths, err := filepath.Glob(filepath.Join(p.dir, "*.wal"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(paths) // 段文件名是定长的十进制编号，字典序即创建顺序。
+
+	var pending []T
+	var first uint64 // pending[0] 的序号。
+	for i, path := range paths {
+		last := i == len(paths)-1
+		seg := walSegment{path: path}
+		err := p.replay(path, last, func(kind byte, seq uint64, data []byte) error {
+			if kind == walAck {
+				p.acked = max(p.acked, seq)
+				return nil
+			}
+			v, err := p.codec.Decode(data)
+			if err != nil {
+				return fmt.Errorf("%w: %s: decode seq %d: %v", ErrWALCorrupted, path, seq, err)
+			}
+			if len(pending) == 0 {
+				first = seq
+			}
+			pending = append(pending, v)
+			p.seq, seg.last = seq, seq
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		p.segments = append(p.segments, seg)
+	}
+
+	for i, v := range pending {
+		if first+uint64(i) > p.acked {
+			p.q.Enqueue(v)
+		}
+	}
+	p.seq = max(p.seq, p.acked)
+	p.compact()
+
+	if len(p.segments) == 0 {
+		return p.roll()
+	}
+	active := p.segments[len(p.segments)-1].path
+	if p.file, err = os.OpenFile(active, os.O_WRONLY|os.O_APPEND, 0); err != nil {
+		return err
+	}
+	info, err := p.file.Stat()
+	if err != nil {
+		return err
+	}
+	p.size = info.Size()
+	return nil
+}
+
+// replay 方法依次读取段文件 path 中的记录并调用 fn。last 表示这是最后一个段，其末尾不完整的记录会被截断。
+func (p *PersistentQueue[T]) replay(path string, last bool, fn func(kind byte, seq uint64, data []byte) error) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for off := 0; off < len(b); {
+		kind, seq, data, n, valid := decodeRecord(b[off:])
+		if n == 0 && last {
+			return os.Truncate(path, int64(off)) // 写入最后一条记录时崩溃，丢弃不完整的部分。
+		}
+		if n == 0 || !valid {
+			return fmt.Errorf("%w: %s at offset %d", ErrWALCorrupted, path, off)
+		}
+		if err := fn(kind, seq, data); err != nil {
+			return err
+		}
+		off += n
+	}
+	return nil
+}
+
+// decodeRecord 函数解码 b 开头的一条记录，返回记录类型、序号、数据和记录的总长度。
+// 头部不完整或声明的长度超出 b 的末尾时 n 为 0；记录完整但校验失败或长度不合法时 valid 为 false。
+func decodeRecord(b []byte) (kind byte, seq uint64, data []byte, n int, valid bool) {
+	if len(b) < 8 {
+		return 0, 0, nil, 0, false
+	}
+	length := int(binary.LittleEndian.Uint32(b[:4]))
+	if len(b) < walHeader+length {
+		return 0, 0, nil, 0, false
+	}
+	n = walHeader + length
+	body := b[8:n]
+	if length < 8 || crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(b[4:8]) {
+		return 0, 0, nil, n, false
+	}
+	return body[0], binary.LittleEndian.Uint64(body[1:9]), body[9:], n, true
+}
+
+// write 方法追加一条记录，并按刷盘策略处理。当前段写满时先创建新的段。调用方需持有 lock。
+func (p *PersistentQueue[T]) write(kind byte, seq uint64, data []byte) error {
+	if p.size >= p.config.segmentSize {
+		if err := p.roll(); err != nil {
+			return err
+		}
+	}
+
+	body := append(p.buf[:0], 0, 0, 0, 0, 0, 0, 0, 0, kind) // 长度和校验和在填充数据后写入。
+	body = binary.LittleEndian.AppendUint64(body, seq)
+	body = append(body, data...)
+	binary.LittleEndian.PutUint32(body[:4], uint32(len(body)-walHeader))
+	binary.LittleEndian.PutUint32(body[4:8], crc32.ChecksumIEEE(body[8:]))
+	p.buf = body
+
+	if _, err := p.file.Write(body); err != nil {
+		p.file.Truncate(p.size) // 丢弃可能写入了一部分的记录，否则之后的记录会被当作损坏的数据。
+		return err
+	}
+	p.size += int64(len(body))
+	if p.config.sync.always {
+		return p.file.Sync()
+	}
+	return nil
+}
+
+// roll 方法创建一个新的段文件作为当前段，并在其开头写入当前的确认序号，
+// 这样旧段被删除后恢复时仍能知道哪些元素已经出队。调用方需持有 lock。
+func (p *PersistentQueue[T]) roll() error {
+	var no uint64
+	if len(p.segments) > 0 {
+		fmt.Sscanf(filepath.Base(p.segments[len(p.segments)-1].path), "%d.wal", &no)
+		no++
+	}
+	path := filepath.Join(p.dir, fmt.Sprintf("%020d.wal", no))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if p.file != nil {
+		p.file.Sync() // 旧段不会再被写入，确保它完整落盘。
+		p.file.Close()
+	}
+	p.file, p.size = f, 0
+	p.segments = append(p.segments, walSegment{path: path})
+	return p.write(walAck, p.acked, nil)
+}
+
+// compact 方法删除除当前段以外所有元素都已出队的段文件，包括只有确认记录的段。
+// 确认序号只增不减，当前段开头总有最新的确认序号，因此删除中间的段不影响恢复。调用方需持有 lock。
+func (p *PersistentQueue[T]) compact() {
+	active := len(p.segments) - 1
+	kept := p.segments[:0]
+	for i, seg := range p.segments {
+		if i < active && seg.last <= p.acked {
+			os.Remove(seg.path)
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	p.segments = kept
+}
+
+// ack 方法为刚刚从内存队列出队的 n 个元素写入确认记录。元素按先进先出的顺序出队，
+// 因此只需记录已出队元素的最大序号。队列关闭且所有元素都已确认后关闭段文件。
+func (p *PersistentQueue[T]) ack(n int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.file == nil {
+		return
+	}
+
+	p.acked += uint64(n)
+	if err := p.write(walAck, p.acked, nil); err != nil {
+		// 元素会在重启后再次出队，仍满足至少一次；磁盘上没有新的确认序号，因此不能删除旧段。
+		if p.err == nil {
+			p.err = err
+		}
+	} else {
+		p.compact()
+	}
+	if !p.q.Status() && p.acked == p.seq {
+		p.closeFile()
+	}
+}
+
+// closeFile 方法刷盘并关闭当前段文件，停止定期刷盘。调用方需持有 lock。
+func (p *PersistentQueue[T]) closeFile() {
+	p.file.Sync()
+	p.file.Close()
+	p.file = nil
+	close(p.stop)
+}
+
+// syncEvery 方法每隔 d 把当前段刷到磁盘，直到段文件被关闭。
+func (p *PersistentQueue[T]) syncEvery(d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.lock.Lock()
+			if p.file != nil {
+				p.file.Sync()
+			}
+			p.lock.Unlock()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Close 方法关闭队列，剩余元素仍可出队，取空后段文件被关闭；未出队的元素保留在磁盘上，下次打开同一目录时恢复。
+func (p *PersistentQueue[T]) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.q.Status() {
+		return
+	}
+
+	p.q.Close()
+	if p.acked == p.seq {
+		p.closeFile() // 以确认序号而不是内存队列判断，已出队但尚未确认的元素仍需写入确认记录。
+	}
+}
+
+// Enqueue 方法先把值 v 追加到预写日志，再放入队列尾部。如果队列已关闭，返回 ErrQueueClosed；
+// 序列化或写入失败时返回对应的错误，元素不会入队。
+func (p *PersistentQueue[T]) Enqueue(v T) error {
+	data, err := p.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.q.Status() {
+		return ErrQueueClosed
+	}
+
+	// 日志与内存队列在同一次加锁中按相同的顺序写入，恢复时的顺序与出队顺序一致。
+	if err = p.write(walEnqueue, p.seq+1, data); err != nil {
+		return err
+	}
+	p.seq++
+	p.segments[len(p.segments)-1].last = p.seq
+	return p.q.Enqueue(v)
+}
+
+// EnqueueContext 方法与 Enqueue 相同。持久化队列不限制容量，入队从不阻塞，因此 ctx 不会影响结果。
+func (p *PersistentQueue[T]) EnqueueContext(ctx context.Context, v T) error {
+	return p.Enqueue(v)
+}
+
+// TryEnqueue 方法与 Enqueue 相同，入队成功时返回 true。
+func (p *PersistentQueue[T]) TryEnqueue(v T) bool {
+	return p.Enqueue(v) == nil
+}
+
+// Dequeue 方法是一个非阻塞的出队方法，取出元素后立即写入确认记录。
+func (p *PersistentQueue[T]) Dequeue() (t T, ok bool, isClose bool) {
+	if t, ok, isClose = p.q.Dequeue(); ok {
+		p.ack(1)
+	}
+	return
+}
+
+// TryDequeue 方法是一个从不阻塞的出队方法，与 Dequeue 相同但不返回关闭状态；队列为空时 ok 为 false。
+func (p *PersistentQueue[T]) TryDequeue() (t T, ok bool) {
+	t, ok, _ = p.Dequeue()
+	return
+}
+
+// takeIf 方法实现 headTaker，取出元素后写入确认记录。
+func (p *PersistentQueue[T]) takeIf(accept func(T) bool) bool {
+	if !p.q.takeIf(accept) {
+		return false
+	}
+	p.ack(1)
+	return true
+}
+
+// Peek 方法返回队列头部的值但不将其移除，也不写入确认记录；队列为空时 ok 为 false。
+func (p *PersistentQueue[T]) Peek() (t T, ok bool) {
+	return p.q.Peek()
+}
+
+// Drain 方法取出队列中当前所有待出队的元素，并用一条确认记录确认它们。队列为空时返回 nil。
+func (p *PersistentQueue[T]) Drain() []T {
+	ts := p.q.Drain()
+	if len(ts) > 0 {
+		p.ack(len(ts))
+	}
+	return ts
+}
+
+// DequeueWait 方法是一个阻塞的出队方法，会一直等待直到有元素出队或队列关闭。
+func (p *PersistentQueue[T]) DequeueWait() (t T, ok bool, isClose bool) {
+	if t, ok, isClose = p.q.DequeueWait(); ok {
+		p.ack(1)
+	}
+	return
+}
+
+// DequeueContext 方法与 DequeueWait 相同，但在等待时会响应 ctx 的取消。
+func (p *PersistentQueue[T]) DequeueContext(ctx context.Context) (t T, ok bool, isClose bool, err error) {
+	if t, ok, isClose, err = p.q.DequeueContext(ctx); ok {
+		p.ack(1)
+	}
+	return
+}
+
+// DequeueFunc 方法不断出队元素并调用 fn 进行处理，直到 fn 返回 false 或队列关闭且为空。
+// 元素在交给 fn 之前已被确认。
+func (p *PersistentQueue[T]) DequeueFunc(fn DequeueFunc[T]) (err error) {
+	for {
+		t, ok, isClose := p.DequeueWait()
+		if !ok {
+			return ErrQueueClosedEmpty
+		}
+
+		if !fn(t, isClose) {
+			return
+		}
+	}
+}
+
+// DequeueFuncContext 方法与 DequeueFunc 相同，但在 ctx 结束时停止出队并返回 ctx.Err()，队列本身不会被关闭。
+func (p *PersistentQueue[T]) DequeueFuncContext(ctx context.Context, fn DequeueFunc[T]) (err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		t, ok, isClose, err := p.DequeueContext(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrQueueClosedEmpty
+		}
+
+		if !fn(t, isClose) {
+			return nil
+		}
+	}
+}
+
+// WALErr 方法返回写入确认记录时遇到的第一个错误，没有错误时返回 nil。
+// 确认记录写入失败的元素已经出队，但会在重启后再次出队；入队记录的写入错误由 Enqueue 直接返回。
+func (p *PersistentQueue[T]) WALErr() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.err
+}
+
+// Count 方法返回尚未出队的元素数量。
+func (p *PersistentQueue[T]) Count() int64 {
+	return p.q.Count()
+}
+
+// Cap 方法返回队列的容量上限。持久化队列不限制容量，总是返回 0。
+func (p *PersistentQueue[T]) Cap() int64 {
+	return 0
+}
+
+// Status 方法用于获取队列的状态，true 表示队列处于打开状态。
+func (p *PersistentQueue[T]) Status() bool {
+	return p.q.Status()
+}
+
+// IsClosed 方法判断队列是否已关闭，等价于 !Status()。
+func (p *PersistentQueue[T]) IsClosed() bool {
+	return p.q.IsClosed()
+}
+
+// Done 方法返回一个在队列关闭时被关闭的通道。
+func (p *PersistentQueue[T]) Done() <-chan struct{} {
+	return p.q.Done()
+}
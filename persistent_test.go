@@ -0,0 +1,168 @@
+package nqueue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// intCodec 是 int 的 Codec 实现。
+type intCodec struct{}
+
+func (intCodec) Encode(v int) ([]byte, error) { return encodeInt(v) }
+
+func (intCodec) Decode(b []byte) (int, error) { return decodeInt(b) }
+
+// drainInts 函数以非阻塞的方式取出队列中的所有元素。
+func drainInts(q Queue[int]) (got []int) {
+	for {
+		v, ok, _ := q.Dequeue()
+		if !ok {
+			return got
+		}
+		got = append(got, v)
+	}
+}
+
+// go test -run TestPersistentQueue -v
+func TestPersistentQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewPersistentQueue[int](dir, intCodec{}, WithSyncPolicy(SyncAlways))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+	for i := 0; i < 3; i++ {
+		q.Dequeue()
+	}
+
+	// 不关闭队列直接重新打开，模拟进程崩溃：已出队的元素不会恢复，其余元素按原顺序恢复。
+	q, err = NewPersistentQueue[int](dir, intCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Count() != 7 {
+		t.Fatalf("Count() after recovery = %d, want 7", q.Count())
+	}
+	if v, _, _ := q.DequeueWait(); v != 3 {
+		t.Fatalf("DequeueWait() after recovery = %d, want 3", v)
+	}
+	q.Enqueue(10)
+
+	// 正常关闭后剩余元素仍可出队；取空后重新打开得到空队列。
+	q.Close()
+	if err := q.Enqueue(11); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Enqueue() after Close = %v, want ErrQueueClosed", err)
+	}
+	var got []int
+	q.DequeueFunc(func(v int, isClose bool) bool {
+		got = append(got, v)
+		return true
+	})
+	if !slices.Equal(got, []int{4, 5, 6, 7, 8, 9, 10}) {
+		t.Fatalf("DequeueFunc() = %v, want [4 5 6 7 8 9 10]", got)
+	}
+
+	q, err = NewPersistentQueue[int](dir, intCodec{})
+	if err != nil || q.Count() != 0 {
+		t.Fatalf("reopen drained queue: Count() = %d, err = %v, want 0, nil", q.Count(), err)
+	}
+}
+
+// go test -run TestPersistentQueueSegments -v
+func TestPersistentQueueSegments(t *testing.T) {
+	dir := t.TempDir()
+	segments := func() int {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+		return len(paths)
+	}
+
+	q, err := NewPersistentQueue[int](dir, intCodec{}, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		q.Enqueue(i)
+	}
+	if segments() < 10 {
+		t.Fatalf("%d segments after 100 enqueues, want >= 10", segments())
+	}
+
+	// 出队后旧段被删除；跨越多个段恢复时仍然只恢复未出队的元素。
+	for i := 0; i < 90; i++ {
+		q.Dequeue()
+	}
+	if n := segments(); n > 6 {
+		t.Fatalf("%d segments after dequeuing 90 of 100, want <= 6", n)
+	}
+	q, err = NewPersistentQueue[int](dir, intCodec{}, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := drainInts(q); !slices.Equal(got, []int{90, 91, 92, 93, 94, 95, 96, 97, 98, 99}) {
+		t.Fatalf("recovered %v, want 90..99", got)
+	}
+}
+
+// go test -run TestPersistentQueueCorruption -v
+func TestPersistentQueueCorruption(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewPersistentQueue[int](dir, intCodec{}, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		q.Enqueue(i)
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	slices.Sort(paths)
+
+	// 最后一个段末尾不完整的记录被截断，之前的元素全部恢复。
+	f, _ := os.OpenFile(paths[len(paths)-1], os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{42, 0, 0, 0, 1, 2})
+	f.Close()
+	q, err = NewPersistentQueue[int](dir, intCodec{}, WithSegmentSize(64))
+	if err != nil || q.Count() != 20 {
+		t.Fatalf("recover with torn tail: Count() = %d, err = %v, want 20, nil", q.Count(), err)
+	}
+
+	// 中间的段损坏时返回 ErrWALCorrupted。
+	b, _ := os.ReadFile(paths[0])
+	b[len(b)-1] ^= 0xff
+	os.WriteFile(paths[0], b, 0o644)
+	if _, err = NewPersistentQueue[int](dir, intCodec{}); !errors.Is(err, ErrWALCorrupted) {
+		t.Fatalf("recover with corrupted segment: err = %v, want ErrWALCorrupted", err)
+	}
+}
+
+// go test -run TestPersistentQueueCorruptedActiveSegment -v
+func TestPersistentQueueCorruptedActiveSegment(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewPersistentQueue[int](dir, intCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		q.Enqueue(i)
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(paths) != 1 {
+		t.Fatalf("%d segments, want 1", len(paths))
+	}
+
+	// 唯一的段也是最后一个段：其中完整但校验失败的记录不是不完整的末尾，返回 ErrWALCorrupted 而不是截断。
+	// 段开头是 roll 写入的确认记录，第一条入队记录的校验和紧随其后的长度字段。
+	b, _ := os.ReadFile(paths[0])
+	b[walHeader+8+4] ^= 0xff
+	os.WriteFile(paths[0], b, 0o644)
+	if _, err = NewPersistentQueue[int](dir, intCodec{}); !errors.Is(err, ErrWALCorrupted) {
+		t.Fatalf("recover with corrupted active segment: err = %v, want ErrWALCorrupted", err)
+	}
+	if after, _ := os.ReadFile(paths[0]); len(after) != len(b) {
+		t.Fatalf("corrupted segment truncated to %d bytes, want %d", len(after), len(b))
+	}
+}
@@ -0,0 +1,323 @@
+package nqueue
+
+import (
+	"cmp"
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// PriorityQueue 是一个按优先级出队的泛型队列，实现了 Queue 接口，可以直接替换 NQueue。
+// 元素按 less 定义的顺序出队，less(a, b) 为 true 表示 a 先于 b 出队；优先级相同的元素按入队顺序出队。
+// 也可以通过 EnqueuePriority 为元素指定优先级等级，等级高的元素先出队，适合让紧急任务越过批量任务。
+// 关闭行为与 NQueue 相同：关闭后拒绝入队，剩余元素仍可出队，取空后 DequeueWait 返回 isClose。
+type PriorityQueue[T any] struct {
+	items     []priorityItem[T] // 以二叉堆形式存储的元素。
+	less      func(a, b T) bool // 元素的优先级比较函数。
+	seq       uint64            // 入队序号，用于保证优先级相同的元素先进先出。
+	status    bool              // 队列的状态，true 表示队列处于打开状态，false 表示队列已关闭。
+	count     atomic.Int64      // 队列中元素的数量，在 lock 保护下修改，读取时无需加锁。
+	lock      sync.Mutex        // 互斥锁，用于保证并发操作时的线程安全。
+	recvCond  *sync.Cond        // 条件变量，用于在队列为空时阻塞出队操作。
+	done      chan struct{}     // 队列关闭时被关闭的通道。
+	zeroValue T                 // 泛型类型的零值。
+}
+
+// priorityItem 是堆中的一个元素及其优先级等级和入队序号。
+type priorityItem[T any] struct {
+	value T
+	level int
+	seq   uint64
+}
+
+var _ Queue[int] = (*PriorityQueue[int])(nil)
+
+// NewPriorityQueue 函数创建一个按 less 排序的优先级队列。
+// less 为 nil 时元素之间不比较，只按 EnqueuePriority 指定的等级和入队顺序出队。
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	q := &PriorityQueue[T]{less: less, status: true}
+	q.recvCond = sync.NewCond(&q.lock)
+	q.done = make(chan struct{})
+	return q
+}
+
+// priorityConfig 是 NewOrderedNQueue 的配置。
+type priorityConfig struct {
+	maxFirst bool // 是否按从大到小的顺序出队。
+}
+
+// PriorityOption 是 NewOrderedNQueue 的可选配置项。
+type PriorityOption func(c *priorityConfig)
+
+// WithMaxFirst 选项让 NewOrderedNQueue 创建的队列按从大到小的顺序出队（最大堆）。
+func WithMaxFirst() PriorityOption {
+	return func(c *priorityConfig) {
+		c.maxFirst = true
+	}
+}
+
+// NewOrderedNQueue 函数为可排序的类型创建一个按自然顺序出队的优先级队列，默认最小的元素先出队。
+// 它与 NewPriorityQueue 使用同一套堆实现，只是不需要传入比较函数。
+func NewOrderedNQueue[T cmp.Ordered](opts ...PriorityOption) *PriorityQueue[T] {
+	var c priorityConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.maxFirst {
+		return NewPriorityQueue(func(a, b T) bool { return cmp.Less(b, a) })
+	}
+	return NewPriorityQueue(cmp.Less[T])
+}
+
+// Close 方法用于关闭队列，并广播通知所有等待的 goroutine。
+func (q *PriorityQueue[T]) Close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.status {
+		return
+	}
+
+	q.status = false
+	close(q.done)
+	q.recvCond.Broadcast()
+}
+
+// Enqueue 方法按优先级插入一个值 v。如果队列已关闭，返回 ErrQueueClosed。
+func (q *PriorityQueue[T]) Enqueue(v T) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if !q.status {
+		return ErrQueueClosed
+	}
+
+	return q.push(v, 0)
+}
+
+// EnqueuePriority 方法以优先级等级 level 插入一个值 v，等级越高越先出队，不需要把优先级编码进 T。
+// 等级相同的元素再按 less 和入队顺序排序；Enqueue 使用等级 0，因此负的等级排在普通元素之后。
+// 如果队列已关闭，返回 ErrQueueClosed。
+func (q *PriorityQueue[T]) EnqueuePriority(level int, v T) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if !q.status {
+		return ErrQueueClosed
+	}
+	return q.push(v, level)
+}
+
+// push 方法把值 v 以等级 level 加入堆中并唤醒等待的出队者。调用方需持有 lock。
+func (q *PriorityQueue[T]) push(v T, level int) error {
+	q.seq++
+	q.items = append(q.items, priorityItem[T]{value: v, level: level, seq: q.seq})
+	q.up(len(q.items) - 1)
+	q.count.Add(1)
+	q.recvCond.Broadcast()
+	return nil
+}
+
+// EnqueueContext 方法与 Enqueue 相同。优先级队列不限制容量，入队从不阻塞，因此 ctx 不会影响结果。
+func (q *PriorityQueue[T]) EnqueueContext(ctx context.Context, v T) error {
+	return q.Enqueue(v)
+}
+
+// TryEnqueue 方法与 Enqueue 相同，入队成功时返回 true，队列已关闭时返回 false。
+func (q *PriorityQueue[T]) TryEnqueue(v T) bool {
+	return q.Enqueue(v) == nil
+}
+
+// Dequeue 方法是一个非阻塞的出队方法，取出优先级最高的元素。
+func (q *PriorityQueue[T]) Dequeue() (t T, ok bool, isClose bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.pop()
+}
+
+// DequeueWait 方法是一个阻塞的出队方法，会一直等待直到有元素出队或队列关闭。
+func (q *PriorityQueue[T]) DequeueWait() (t T, ok bool, isClose bool) {
+	t, ok, isClose, _ = q.DequeueContext(context.Background())
+	return
+}
+
+// DequeueContext 方法与 DequeueWait 相同，但在等待时会响应 ctx 的取消。
+func (q *PriorityQueue[T]) DequeueContext(ctx context.Context) (t T, ok bool, isClose bool, err error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	ready := func() bool { return len(q.items) > 0 || !q.status }
+	if err = waitCond(ctx, q.recvCond, ready, (*sync.Cond).Wait); err != nil {
+		return q.zeroValue, false, !q.status, err
+	}
+
+	t, ok, isClose = q.pop()
+	return
+}
+
+// DequeueFunc 方法不断按优先级出队元素并调用 fn 进行处理，直到 fn 返回 false 或队列关闭且为空。
+func (q *PriorityQueue[T]) DequeueFunc(fn DequeueFunc[T]) (err error) {
+	for {
+		t, ok, isClose := q.DequeueWait()
+		if !ok {
+			return ErrQueueClosedEmpty
+		}
+
+		if !fn(t, isClose) {
+			return
+		}
+	}
+}
+
+// DequeueFuncContext 方法与 DequeueFunc 相同，但在 ctx 结束时停止出队并返回 ctx.Err()，队列本身不会被关闭。
+func (q *PriorityQueue[T]) DequeueFuncContext(ctx context.Context, fn DequeueFunc[T]) (err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err // 每次出队前检查，队列中一直有元素时也能及时停止。
+		}
+
+		t, ok, isClose, err := q.DequeueContext(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrQueueClosedEmpty
+		}
+
+		if !fn(t, isClose) {
+			return nil
+		}
+	}
+}
+
+// TryDequeue 方法是一个从不阻塞的出队方法，取出优先级最高的元素；队列为空时 ok 为 false。
+func (q *PriorityQueue[T]) TryDequeue() (t T, ok bool) {
+	t, ok, _ = q.Dequeue()
+	return
+}
+
+// Drain 方法在一次加锁中按优先级顺序取出队列中的所有元素，队列为空时返回 nil。
+func (q *PriorityQueue[T]) Drain() (ts []T) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.items) > 0 {
+		t, _, _ := q.pop()
+		ts = append(ts, t)
+	}
+	return
+}
+
+// Peek 方法返回优先级最高的元素但不将其移除；队列为空时 ok 为 false。
+func (q *PriorityQueue[T]) Peek() (t T, ok bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.items) == 0 {
+		return q.zeroValue, false
+	}
+	return q.items[0].value, true
+}
+
+// Count 方法用于获取队列中元素的数量。
+func (q *PriorityQueue[T]) Count() int64 {
+	return q.count.Load()
+}
+
+// Cap 方法返回队列的容量上限。优先级队列不限制容量，总是返回 0。
+func (q *PriorityQueue[T]) Cap() int64 {
+	return 0
+}
+
+// Status 方法用于获取队列的状态，true 表示队列处于打开状态。
+func (q *PriorityQueue[T]) Status() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.status
+}
+
+// IsClosed 方法判断队列是否已关闭，等价于 !Status()。
+func (q *PriorityQueue[T]) IsClosed() bool {
+	return !q.Status()
+}
+
+// Done 方法返回一个在队列关闭时被关闭的通道。
+func (q *PriorityQueue[T]) Done() <-chan struct{} {
+	return q.done
+}
+
+// takeIf 方法实现 headTaker，参见 Transfer。
+func (q *PriorityQueue[T]) takeIf(accept func(T) bool) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.items) == 0 || !accept(q.items[0].value) {
+		return false
+	}
+	q.pop()
+	return true
+}
+
+// pop 方法取出堆顶元素。调用方需持有 lock。
+func (q *PriorityQueue[T]) pop() (t T, ok bool, isClose bool) {
+	isClose = !q.status
+	if len(q.items) == 0 {
+		return q.zeroValue, false, isClose
+	}
+
+	t = q.items[0].value
+	last := len(q.items) - 1
+	q.items[0] = q.items[last]
+	q.items[last] = priorityItem[T]{} // 释放对元素的引用。
+	q.items = q.items[:last]
+	if last > 0 {
+		q.down(0)
+	}
+	q.count.Add(-1)
+	return t, true, isClose
+}
+
+// before 方法判断第 i 个元素是否应先于第 j 个元素出队。
+func (q *PriorityQueue[T]) before(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if a.level != b.level {
+		return a.level > b.level
+	}
+	if q.less != nil {
+		if q.less(a.value, b.value) {
+			return true
+		}
+		if q.less(b.value, a.value) {
+			return false
+		}
+	}
+	return a.seq < b.seq
+}
+
+// up 方法把第 i 个元素向上调整到合适的位置。
+func (q *PriorityQueue[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.before(i, parent) {
+			return
+		}
+		q.items[i], q.items[parent] = q.items[parent], q.items[i]
+		i = parent
+	}
+}
+
+// down 方法把第 i 个元素向下调整到合适的位置。
+func (q *PriorityQueue[T]) down(i int) {
+	n := len(q.items)
+	for {
+		first := i
+		if l := 2*i + 1; l < n && q.before(l, first) {
+			first = l
+		}
+		if r := 2*i + 2; r < n && q.before(r, first) {
+			first = r
+		}
+		if first == i {
+			return
+		}
+		q.items[i], q.items[first] = q.items[first], q.items[i]
+		i = first
+	}
+}
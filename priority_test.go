@@ -0,0 +1,116 @@
+package nqueue
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+// go test -run TestOrderedNQueue -v
+func TestOrderedNQueue(t *testing.T) {
+	values := rand.Perm(1000)
+
+	drain := func(q *PriorityQueue[int]) []int {
+		for _, v := range values {
+			q.Enqueue(v)
+		}
+		q.Close()
+
+		var got []int
+		q.DequeueFunc(func(v int, isClose bool) bool {
+			got = append(got, v)
+			return true
+		})
+		return got
+	}
+
+	got := drain(NewOrderedNQueue[int]())
+	if !slices.IsSorted(got) || len(got) != len(values) {
+		t.Fatalf("min-first order broken: %v", got[:10])
+	}
+
+	got = drain(NewOrderedNQueue[int](WithMaxFirst()))
+	slices.Reverse(got)
+	if !slices.IsSorted(got) || len(got) != len(values) {
+		t.Fatalf("max-first order broken: %v", got[:10])
+	}
+}
+
+// go test -run TestPriorityQueue -v
+func TestPriorityQueue(t *testing.T) {
+	type job struct {
+		priority int
+		name     string
+	}
+	q := NewPriorityQueue(func(a, b job) bool { return a.priority > b.priority })
+
+	q.Enqueue(job{1, "bulk-1"})
+	q.Enqueue(job{1, "bulk-2"})
+	q.Enqueue(job{9, "urgent"})
+	q.Enqueue(job{1, "bulk-3"})
+
+	if v, ok := q.Peek(); !ok || v.name != "urgent" {
+		t.Fatalf("Peek() = %v, %v, want urgent, true", v, ok)
+	}
+
+	// 优先级相同的元素按入队顺序出队。
+	for _, want := range []string{"urgent", "bulk-1", "bulk-2", "bulk-3"} {
+		if v, ok, _ := q.Dequeue(); !ok || v.name != want {
+			t.Fatalf("Dequeue() = %v, %v, want %s, true", v, ok, want)
+		}
+	}
+	if q.Count() != 0 {
+		t.Fatalf("Count() = %d, want 0", q.Count())
+	}
+
+	// DequeueWait 阻塞直到有元素入队。
+	got := make(chan job, 1)
+	go func() {
+		v, _, _ := q.DequeueWait()
+		got <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.Enqueue(job{5, "late"})
+	if v := <-got; v.name != "late" {
+		t.Fatalf("DequeueWait() = %v, want late", v)
+	}
+
+	q.Close()
+	if err := q.Enqueue(job{}); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Enqueue() after Close = %v, want ErrQueueClosed", err)
+	}
+	if _, ok, isClose := q.DequeueWait(); ok || !isClose {
+		t.Fatalf("DequeueWait() on closed queue = %v, %v, want false, true", ok, isClose)
+	}
+	<-q.Done()
+}
+
+// go test -run TestEnqueuePriority -v
+func TestEnqueuePriority(t *testing.T) {
+	// 不设置 less 时只按等级和入队顺序出队，普通元素使用等级 0。
+	var q Queue[string] = NewPriorityQueue[string](nil)
+	pq := q.(*PriorityQueue[string])
+	q.Enqueue("bulk-1")
+	pq.EnqueuePriority(-1, "background")
+	pq.EnqueuePriority(5, "urgent-1")
+	q.Enqueue("bulk-2")
+	pq.EnqueuePriority(5, "urgent-2")
+	pq.EnqueuePriority(9, "critical")
+	q.Close()
+
+	// 关闭后剩余元素通过 DequeueFunc 依次取出，与 NQueue 的语义相同。
+	var got []string
+	err := q.DequeueFunc(func(v string, isClose bool) bool {
+		got = append(got, v)
+		return true
+	})
+	want := []string{"critical", "urgent-1", "urgent-2", "bulk-1", "bulk-2", "background"}
+	if !errors.Is(err, ErrQueueClosedEmpty) || !slices.Equal(got, want) {
+		t.Fatalf("DequeueFunc() = %v, got %v, want %v", err, got, want)
+	}
+	if err := pq.EnqueuePriority(1, "late"); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("EnqueuePriority() after Close = %v, want ErrQueueClosed", err)
+	}
+}
@@ -1,13 +1,52 @@
 package nqueue
 
+import "context"
+
 type DequeueFunc[T any] func(t T, isClose bool) bool
 
+// Queue 是 NQueue 和 PriorityQueue 共同实现的队列接口。
+//
+// 出队方法通过 ok 和 isClose 两个标志报告结果，调用方不应根据返回值本身判断：
+//   - ok 为 true 表示确实取出了一个入队过的元素，即使它是 nil 指针、nil 接口或零值；此时 isClose 只表示队列是否已关闭。
+//   - ok 为 false 且 isClose 为 true 是唯一的“已关闭且取空”信号，此时返回的值总是 T 的零值。
+//   - ok 和 isClose 都为 false 只会出现在非阻塞的 Dequeue 遇到空队列，或 DequeueContext 的 ctx 结束时。
+//
+// Count 返回当前的元素数量，Cap 返回容量上限（0 表示不限制容量），调用方可以据此自行实现限流或丢弃；
+// TryEnqueue 是不阻塞的入队，队列已满或已关闭时返回 false。
+// TryDequeue 和 Peek 从不阻塞，Drain 一次取出当前所有待出队的元素，适合关闭时清空队列或轮询式的集成。
 type Queue[T any] interface {
 	Close()
 	Enqueue(T) error
+	EnqueueContext(ctx context.Context, v T) error
+	TryEnqueue(v T) bool
 	Dequeue() (t T, ok bool, isClose bool)
+	TryDequeue() (t T, ok bool)
+	Peek() (t T, ok bool)
+	Drain() []T
 	DequeueWait() (t T, ok bool, isClose bool)
+	DequeueContext(ctx context.Context) (t T, ok bool, isClose bool, err error)
 	DequeueFunc(fn DequeueFunc[T]) (err error)
+	DequeueFuncContext(ctx context.Context, fn DequeueFunc[T]) (err error)
 	Count() int64
+	Cap() int64
 	Status() bool
+	IsClosed() bool
+	Done() <-chan struct{}
+}
+
+// ReadQueue 是只能出队的队列视图，通过 NQueue.ReadOnly 获得，用于只允许消费者出队的 API 边界。
+type ReadQueue[T any] interface {
+	DequeueWait() (t T, ok bool, isClose bool)
+	DequeueFunc(fn DequeueFunc[T]) (err error)
+	Count() int64
+	IsClosed() bool
+}
+
+// WriteQueue 是只能入队的队列视图，通过 NQueue.WriteOnly 获得，用于只允许生产者入队的 API 边界。
+type WriteQueue[T any] interface {
+	Enqueue(T) error
+	TryEnqueue(v T) bool
+	Count() int64
+	Cap() int64
+	IsClosed() bool
 }
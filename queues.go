@@ -0,0 +1,219 @@
+package nqueue
+
+import "context"
+
+// nonBlocking 是一个已经取消的 context。由于条件满足时优先完成操作，把它传给 EnqueueContext
+// 可以实现不阻塞的入队：有空位时入队，队列已满时立即返回 context.Canceled。
+var nonBlocking = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// LeastLoaded 函数返回 queues 中元素数量最少的队列的下标，数量相同时返回靠前的下标。
+// queues 为空时返回 -1。
+// 它只读取每个队列的 Count()，适合在分片场景中代替轮询，把元素路由到负载最低的分片。
+func LeastLoaded[T any](queues ...Queue[T]) int {
+	idx := -1
+	var least int64
+	for i, q := range queues {
+		if n := q.Count(); idx < 0 || n < least {
+			idx, least = i, n
+		}
+	}
+	return idx
+}
+
+// TotalCount 函数返回 queues 中所有队列的元素数量之和。
+// 各队列的 Count() 分别读取，并发修改时结果只是近似值，适合用于监控分片的总积压。
+func TotalCount[T any](queues ...Queue[T]) int64 {
+	var total int64
+	for _, q := range queues {
+		total += q.Count()
+	}
+	return total
+}
+
+// MaxCount 函数返回 queues 中元素数量最多的队列的下标和它的元素数量，数量相同时返回靠前的下标，
+// 用于发现分片中的热点。queues 为空时返回 -1 和 0。
+func MaxCount[T any](queues ...Queue[T]) (idx int, n int64) {
+	idx = -1
+	for i, q := range queues {
+		if c := q.Count(); idx < 0 || c > n {
+			idx, n = i, c
+		}
+	}
+	return idx, n
+}
+
+// Transfer 函数把 src 头部最多 max 个元素按先进先出的顺序移动到 dst 的尾部，返回实际移动的数量。
+// 移动数量受 src 中的元素数量和 dst 的剩余容量限制；dst 已关闭时不移动任何元素。
+// 开启 WithSpill 的队列之间只移动内存中的元素，移动数量还受 dst 内存上限的限制。
+// dst 开启了 WithTimestamps 而 src 没有开启时，移动的元素从放入 dst 时开始计时。
+//
+// 当 src 和 dst 都是 *NQueue 时，两个队列会按固定顺序同时加锁，节点直接重新链接，
+// 整个移动是原子的，与两边的并发入队和出队互不干扰。
+// src 是这个包中的其他队列实现时，每个元素在持有 src 的锁时先放入 dst，成功后才从 src 取出，
+// dst 拒绝的元素留在 src 的头部，不会丢失也不会改变顺序；此时不能在相反的方向上并发地 Transfer 同一对队列。
+// 其他 Queue 实现会逐个出队再入队，不保证原子性：dst 在检查之后被并发占满或关闭时，
+// 已取出的元素放回 src 的尾部，src 也已关闭时改为阻塞地放入 dst。
+func Transfer[T any](dst, src Queue[T], max int) int {
+	if max <= 0 || dst == src {
+		return 0
+	}
+
+	d, dok := dst.(*NQueue[T])
+	s, sok := src.(*NQueue[T])
+	if dok && sok {
+		return transferNodes(d, s, max)
+	}
+
+	moved := 0
+	accept := func(t T) bool { return dst.EnqueueContext(nonBlocking, t) == nil }
+	if h, ok := src.(headTaker[T]); ok {
+		held := func(t T) bool {
+			enterNested() // takeIf 在持有 src 的锁时调用它。
+			defer exitNested()
+			return accept(t)
+		}
+		for moved < max && h.takeIf(held) {
+			moved++
+		}
+		return moved
+	}
+
+	for moved < max && !dst.IsClosed() && (dst.Cap() == 0 || dst.Count() < dst.Cap()) {
+		t, ok, _ := src.Dequeue()
+		if !ok {
+			break
+		}
+		if !accept(t) {
+			if src.Enqueue(t) != nil {
+				dst.Enqueue(t) // src 也无法放回，只能等待 dst 的空位。
+			}
+			break
+		}
+		moved++
+	}
+	return moved
+}
+
+// headTaker 由这个包中的队列实现，Transfer 用它在确认 dst 接收之后才从 src 取出元素。
+type headTaker[T any] interface {
+	// takeIf 方法在持有锁时把头部元素交给 accept，accept 返回 true 时取出该元素并返回 true；
+	// 队列为空或 accept 返回 false 时元素留在队列中，返回 false。
+	takeIf(accept func(T) bool) bool
+}
+
+// takeIf 方法实现 headTaker，参见 Transfer。
+func (q *NQueue[T]) takeIf(accept func(T) bool) bool {
+	q.schedPoint()
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	if q.head == nil || !accept(q.head.value) {
+		return false
+	}
+	q.pop()
+	return true
+}
+
+// transferNodes 函数在同时持有两个队列锁的情况下，把 src 头部的节点重新链接到 dst 的尾部。
+func transferNodes[T any](dst, src *NQueue[T], max int) int {
+	unlock := lockPair(dst, src)
+	defer unlock()
+
+	if !dst.status {
+		return 0
+	}
+
+	n := min(int64(max), src.count.Load())
+	if dst.capacity > 0 {
+		n = min(n, dst.capacity-dst.count.Load())
+	}
+	if room, limited := dst.memRoom(); limited {
+		n = min(n, room) // 转移只链接节点，不会写入 dst 的溢出文件。
+	}
+	if n <= 0 {
+		return 0
+	}
+
+	first, moved := src.popChain(int(n))
+	if !src.stamped {
+		dst.restamp(first) // src 没有记录入队时间，从放入 dst 时开始计时。
+	}
+	dst.pushChain(first)
+	return int(moved)
+}
+
+// lockPair 函数按队列编号的顺序锁住两个不同的队列，返回解锁函数。
+func lockPair[T any](a, b *NQueue[T]) (unlock func()) {
+	if a.id > b.id {
+		a, b = b, a
+	}
+	a.recvLock.Lock()
+	b.recvLock.Lock()
+	return func() {
+		b.recvLock.Unlock()
+		a.recvLock.Unlock()
+	}
+}
+
+// Swap 方法原子地交换两个队列中的待出队元素，适合一个队列积累、另一个队列处理的双缓冲模式。
+// 交换期间两个队列会被同时锁住，交换后两个队列都保持原有的状态和配置，Count() 反映交换后的内容。
+// 交换不是入队或出队：Stats 中的 Enqueued 和 Dequeued、Hooks 和 WithSoftLimit 的回调都不受影响；
+// 队列因交换变为空时仍会调用 WithOnEmpty 的回调。
+// 已通过 DequeueAck 取出但未确认的元素仍属于原队列。交换后的元素数量可能超过有界队列的容量，
+// 此时入队会阻塞，直到元素数量回落到容量以下。
+//
+// 任意一个队列已关闭时返回 ErrQueueClosed，关闭的队列不能再接收元素；
+// 开启 WithSpill 的队列有元素溢出到磁盘时返回 ErrQueueSpilled，溢出区属于队列的配置，不随元素交换。
+// 返回错误时两个队列都不会被修改。
+func (q *NQueue[T]) Swap(other *NQueue[T]) error {
+	if q == other {
+		return nil
+	}
+
+	unlock := lockPair(q, other)
+	defer unlock()
+	if !q.status || !other.status {
+		return ErrQueueClosed
+	}
+	if q.spilled() || other.spilled() {
+		return ErrQueueSpilled
+	}
+
+	qCount, otherCount := q.count.Load(), other.count.Load()
+	q.head, other.head = other.head, q.head
+	q.tail, other.tail = other.tail, q.tail
+	if !other.stamped {
+		q.restamp(q.head) // 来自没有记录入队时间的队列，从交换时开始计时。
+	}
+	if !q.stamped {
+		other.restamp(other.head)
+	}
+	q.swapped(qCount, otherCount)
+	other.swapped(otherCount, qCount)
+	return nil
+}
+
+// swapped 方法在队列内容由 old 个元素被替换为 count 个元素后直接更新元素数量和字节数，并通知等待者；
+// 队列因此从非空变为空时与出队一样调用 WithOnEmpty 的回调。调用方需持有 recvLock。
+func (q *NQueue[T]) swapped(old, count int64) {
+	q.count.Store(count)
+	if old > 0 && count == 0 && q.onEmpty != nil {
+		go q.onEmpty()
+	}
+	if q.sizeOf != nil {
+		q.bytes.Store(q.chainBytes(q.head))
+	}
+	q.peak = max(q.peak, count)
+	if count > 0 {
+		q.recvCond.Broadcast()
+	}
+	if q.capacity > 0 {
+		q.sendCond.Broadcast()
+	}
+	if q.isEmpty() {
+		q.emptied()
+	}
+}
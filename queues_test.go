@@ -0,0 +1,321 @@
+package nqueue
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -run TestLeastLoaded -v
+func TestLeastLoaded(t *testing.T) {
+	if idx := LeastLoaded[int](); idx != -1 {
+		t.Fatalf("LeastLoaded() = %d, want -1", idx)
+	}
+
+	queues := make([]Queue[int], 3)
+	for i := range queues {
+		queues[i] = NewNQueue[int]()
+	}
+	queues[0].Enqueue(1)
+	queues[0].Enqueue(1)
+	queues[1].Enqueue(1)
+	queues[2].Enqueue(1)
+
+	if idx := LeastLoaded(queues...); idx != 1 {
+		t.Fatalf("LeastLoaded() = %d, want 1", idx)
+	}
+
+	queues[1].Enqueue(1)
+	if idx := LeastLoaded(queues...); idx != 2 {
+		t.Fatalf("LeastLoaded() = %d, want 2", idx)
+	}
+}
+
+// go test -run TestTotalCount -v
+func TestTotalCount(t *testing.T) {
+	if n := TotalCount[int](); n != 0 {
+		t.Fatalf("TotalCount() = %d, want 0", n)
+	}
+	if idx, n := MaxCount[int](); idx != -1 || n != 0 {
+		t.Fatalf("MaxCount() = %d, %d, want -1, 0", idx, n)
+	}
+
+	// 混合使用不同的 Queue 实现。
+	queues := []Queue[int]{NewNQueue[int](), NewOrderedNQueue[int](), NewNQueue[int]()}
+	for i, n := range []int{2, 5, 5} {
+		for j := 0; j < n; j++ {
+			queues[i].Enqueue(j)
+		}
+	}
+
+	if n := TotalCount(queues...); n != 12 {
+		t.Fatalf("TotalCount() = %d, want 12", n)
+	}
+	if idx, n := MaxCount(queues...); idx != 1 || n != 5 {
+		t.Fatalf("MaxCount() = %d, %d, want 1, 5", idx, n)
+	}
+}
+
+// go test -run none -bench BenchmarkRouting -benchmem
+func BenchmarkRouting(b *testing.B) {
+	const shareSize = 32
+	newShards := func() []Queue[int] {
+		queues := make([]Queue[int], shareSize)
+		for i := range queues {
+			queues[i] = NewNQueue[int]()
+		}
+		return queues
+	}
+
+	b.Run("RoundRobin", func(b *testing.B) {
+		queues := newShards()
+		var qc atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				queues[qc.Add(1)%shareSize].Enqueue(1)
+			}
+		})
+	})
+
+	b.Run("LeastLoaded", func(b *testing.B) {
+		queues := newShards()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				queues[LeastLoaded(queues...)].Enqueue(1)
+			}
+		})
+	})
+}
+
+// go test -run TestTransfer -v
+func TestTransfer(t *testing.T) {
+	src, dst := NewNQueue[int](), NewNQueueWithCap[int](5)
+	for i := 0; i < 10; i++ {
+		src.Enqueue(i)
+	}
+	dst.Enqueue(-1)
+
+	// dst 只剩 4 个空位。
+	if n := Transfer[int](dst, src, 6); n != 4 {
+		t.Fatalf("Transfer() = %d, want 4", n)
+	}
+	if ts := dst.Snapshot(); fmt.Sprint(ts) != "[-1 0 1 2 3]" {
+		t.Fatalf("dst = %v, want [-1 0 1 2 3]", ts)
+	}
+	if ts := src.Snapshot(); fmt.Sprint(ts) != "[4 5 6 7 8 9]" {
+		t.Fatalf("src = %v, want [4 5 6 7 8 9]", ts)
+	}
+	if n := Transfer[int](src, src, 1); n != 0 {
+		t.Fatalf("Transfer() to itself = %d, want 0", n)
+	}
+
+	dst.Close()
+	if n := Transfer[int](dst, src, 1); n != 0 {
+		t.Fatalf("Transfer() to closed queue = %d, want 0", n)
+	}
+}
+
+// go test -run TestTransferMixed -v
+func TestTransferMixed(t *testing.T) {
+	src := NewNQueue[int]()
+	for i := 1; i <= 3; i++ {
+		src.Enqueue(i)
+	}
+
+	// dst 不是 *NQueue：被拒绝的元素留在 src 的头部，即使 src 已关闭也不会丢失或改变顺序。
+	dst := NewPriorityQueue[int](nil)
+	if n := Transfer[int](dst, src, 1); n != 1 || dst.Count() != 1 {
+		t.Fatalf("Transfer() = %d, dst.Count() = %d, want 1, 1", n, dst.Count())
+	}
+	dst.Close()
+	src.Close()
+	if n := Transfer[int](dst, src, 2); n != 0 {
+		t.Fatalf("Transfer() to closed queue = %d, want 0", n)
+	}
+	if ts := src.Snapshot(); fmt.Sprint(ts) != "[2 3]" {
+		t.Fatalf("src = %v, want [2 3]", ts)
+	}
+
+	// src 不是 *NQueue 时元素在放入 dst 之后才从 src 取出。
+	back := NewNQueueWithCap[int](1)
+	if n := Transfer[int](back, dst, 5); n != 1 || dst.Count() != 0 || back.Count() != 1 {
+		t.Fatalf("Transfer() = %d, dst.Count() = %d, back.Count() = %d, want 1, 0, 1", n, dst.Count(), back.Count())
+	}
+
+	// 没有记录入队时间的元素进入开启 WithTimestamps 的队列后从移动时开始计时。
+	plain, stamped := NewNQueue[int](), NewNQueue(WithTimestamps[int]())
+	plain.Enqueue(1)
+	Transfer[int](stamped, plain, 1)
+	if age, ok := stamped.OldestAge(); !ok || age > time.Second {
+		t.Fatalf("OldestAge() after Transfer = %v, %v, want < 1s", age, ok)
+	}
+}
+
+// go test -run TestTransferContended -v
+func TestTransferContended(t *testing.T) {
+	queues := [2]*NQueue[int]{NewNQueue[int](), NewNQueue[int]()}
+
+	const producers, perProducer = 8, 5000
+	seen := make([]atomic.Int32, producers*perProducer)
+
+	var wg, wg1 sync.WaitGroup
+	wg.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				queues[i%2].Enqueue(p*perProducer + i)
+			}
+		}(p)
+	}
+
+	wg1.Add(4)
+	for c := 0; c < 4; c++ {
+		go func(q *NQueue[int]) {
+			defer wg1.Done()
+			for {
+				v, ok, _ := q.DequeueWait()
+				if !ok {
+					return
+				}
+				seen[v].Add(1)
+			}
+		}(queues[c%2])
+	}
+
+	stop := make(chan struct{})
+	rebalanced := make(chan struct{})
+	go func() {
+		defer close(rebalanced)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				Transfer[int](queues[i%2], queues[(i+1)%2], 16)
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	<-rebalanced
+	queues[0].Close()
+	queues[1].Close()
+	wg1.Wait()
+
+	for v := range seen {
+		if n := seen[v].Load(); n != 1 {
+			t.Fatalf("item %d delivered %d times, want 1", v, n)
+		}
+	}
+}
+
+// go test -run TestSwap -v
+func TestSwap(t *testing.T) {
+	a, b := NewNQueue[int](), NewNQueue[int]()
+	a.Enqueue(1)
+	a.Enqueue(2)
+	b.Enqueue(3)
+
+	a.Swap(b)
+	if fmt.Sprint(a.Snapshot(), b.Snapshot()) != "[3] [1 2]" {
+		t.Fatalf("after Swap a = %v, b = %v, want [3] [1 2]", a.Snapshot(), b.Snapshot())
+	}
+	if a.Count() != 1 || b.Count() != 2 {
+		t.Fatalf("Count() = %d, %d, want 1, 2", a.Count(), b.Count())
+	}
+	a.Enqueue(4)
+	b.Enqueue(5)
+	if fmt.Sprint(a.Snapshot(), b.Snapshot()) != "[3 4] [1 2 5]" {
+		t.Fatalf("after Enqueue a = %v, b = %v, want [3 4] [1 2 5]", a.Snapshot(), b.Snapshot())
+	}
+}
+
+// go test -run TestSwapOnEmpty -v
+func TestSwapOnEmpty(t *testing.T) {
+	var fired atomic.Int64
+	q := NewNQueue(WithOnEmpty[int](func() { fired.Add(1) }))
+	other := NewNQueue[int]()
+	q.Enqueue(1)
+
+	if err := q.Swap(other); err != nil {
+		t.Fatalf("Swap() = %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for fired.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := fired.Load(); n != 1 {
+		t.Fatalf("onEmpty fired %d times after swapping to empty, want 1", n)
+	}
+
+	// 空队列与空队列交换时保持为空，不会再次触发。
+	if err := q.Swap(NewNQueue[int]()); err != nil {
+		t.Fatalf("Swap() = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := fired.Load(); n != 1 {
+		t.Fatalf("onEmpty fired %d times, want 1", n)
+	}
+}
+
+// go test -run TestSwapRejected -v
+func TestSwapRejected(t *testing.T) {
+	open, closed := NewNQueue[int](), NewNQueue[int]()
+	open.Enqueue(1)
+	closed.Close()
+
+	// 与已关闭且已取空的队列交换会让它在终止状态下重新出现元素，因此被拒绝，两个队列都不变。
+	if err := open.Swap(closed); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Swap() with a closed queue = %v, want ErrQueueClosed", err)
+	}
+	if open.Count() != 1 || closed.Count() != 0 || !closed.IsDrained() {
+		t.Fatalf("after rejected Swap: Count() = %d, %d, IsDrained() = %v", open.Count(), closed.Count(), closed.IsDrained())
+	}
+
+	// 溢出到磁盘的元素不随交换移动，有溢出元素时同样拒绝。
+	spilled := NewNQueue(WithSpill(t.TempDir(), 1, encodeInt, decodeInt))
+	spilled.Enqueue(1)
+	spilled.Enqueue(2)
+	if err := spilled.Swap(NewNQueue[int]()); !errors.Is(err, ErrQueueSpilled) || spilled.Count() != 2 {
+		t.Fatalf("Swap() with spilled items = %v, Count() = %d, want ErrQueueSpilled, 2", err, spilled.Count())
+	}
+}
+
+// go test -run TestSwapDoubleBuffer -v
+func TestSwapDoubleBuffer(t *testing.T) {
+	front, back := NewNQueue[int](), NewNQueue[int]()
+
+	const n = 100000
+	go func() {
+		for i := 0; i < n; i++ {
+			front.Enqueue(i)
+		}
+	}()
+
+	next := 0
+	for next < n {
+		front.Swap(back) // back 已被处理完，交换后 front 继续积累新的元素。
+		for {
+			v, ok, _ := back.Dequeue()
+			if !ok {
+				break
+			}
+			if v != next {
+				t.Fatalf("processed %d, want %d", v, next)
+			}
+			next++
+		}
+	}
+
+	if front.Count() != 0 || back.Count() != 0 {
+		t.Fatalf("Count() = %d, %d, want 0, 0", front.Count(), back.Count())
+	}
+	if front.IsClosed() || back.IsClosed() {
+		t.Fatal("queues closed after Swap")
+	}
+}
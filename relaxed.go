@@ -0,0 +1,266 @@
+package nqueue
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// RelaxedQueue 是一个放宽先进先出顺序以换取吞吐量的队列，实现了 Queue 接口。
+// 它由多条互相独立的通道（lane）组成，每条通道都是一个 NQueue：入队按轮询分散到各条通道，
+// 出队从轮换的起点开始依次尝试各条通道，因此大量生产者和消费者不再争用同一把锁。
+//
+// 顺序保证：进入同一条通道的元素按入队顺序出队；不同通道之间没有顺序保证，
+// 同一个 goroutine 先后入队的两个元素也可能进入不同的通道而被交换顺序。
+// 消费者每次出队都会换一条通道作为起点，任何通道中的元素都不会被无限期推迟。
+// 需要严格先进先出时应使用 NQueue，这是它的默认行为。
+//
+// 需要按键分片时使用 NewShardedQueue，相同键的元素总是进入同一条通道，因此彼此之间保持先进先出。
+//
+// 放宽顺序没有做成 NQueue 的 WithRelaxedOrdering 选项，而是一个独立的类型：NQueue 的 Peek、Snapshot、Cursor、
+// Swap、Transfer、DequeueAck 的重新投递和 WithSpill 都依赖同一把锁下的单个链表，分成多条通道后
+// 这些方法要么失去严格先进先出的保证，要么重新锁住所有通道而抵消吞吐量的收益。
+// 独立的类型让较弱的顺序保证体现在类型上，调用方无法在不知情的情况下拿到一个乱序的 NQueue，
+// NQueue 的热路径上也不需要为每次操作判断是否分了通道。两者都实现了 Queue 接口，可以互相替换。
+//
+// 关闭行为与 NQueue 相同：关闭后拒绝入队，剩余元素仍可出队，取空后 DequeueWait 返回 isClose。
+type RelaxedQueue[T any] struct {
+	lanes   []*NQueue[T]  // 互相独立的通道。
+	put     atomic.Uint64 // 入队的轮询计数。
+	take    atomic.Uint64 // 出队起点的轮换计数。
+	closed  atomic.Bool   // 队列是否已关闭。
+	waiting atomic.Int64  // 正在阻塞等待元素的消费者数量，为 0 时入队不需要加锁唤醒。
+	lock    sync.Mutex    // 保护阻塞等待的互斥锁，只有存在等待的消费者时入队才会使用。
+	cond    *sync.Cond    // 条件变量，用于在所有通道都为空时阻塞出队操作。
+	done    chan struct{} // 队列关闭时被关闭的通道。
+
+	key func(T) uint64 // NewShardedQueue 设置的分片键函数，为 nil 时按轮询选择通道。
+}
+
+var _ Queue[int] = (*RelaxedQueue[int])(nil)
+
+// NewRelaxedQueue 函数创建一个由 lanes 条通道组成的放宽顺序的队列，lanes 小于等于 0 时使用 GOMAXPROCS。
+func NewRelaxedQueue[T any](lanes int) *RelaxedQueue[T] {
+	if lanes <= 0 {
+		lanes = runtime.GOMAXPROCS(0)
+	}
+
+	r := &RelaxedQueue[T]{lanes: make([]*NQueue[T], lanes)}
+	for i := range r.lanes {
+		r.lanes[i] = NewNQueue[T]()
+	}
+	r.cond = sync.NewCond(&r.lock)
+	r.done = make(chan struct{})
+	return r
+}
+
+// NewShardedQueue 函数创建一个由 shards 个分片组成的队列，用于替代手工维护一组 NQueue 并分散负载的做法。
+// key 为 nil 时与 NewRelaxedQueue 相同，入队按轮询选择分片；否则元素进入 key(v) 对分片数取模的分片，
+// 相同键的元素按入队顺序出队。无论哪种方式，出队都会在当前分片为空时依次尝试其他分片，
+// 只要任意分片中有元素，DequeueWait 就不会阻塞。shards 小于等于 0 时使用 GOMAXPROCS。
+func NewShardedQueue[T any](shards int, key func(T) uint64) *RelaxedQueue[T] {
+	r := NewRelaxedQueue[T](shards)
+	r.key = key
+	return r
+}
+
+// Close 方法用于关闭队列的所有通道，并广播通知所有等待的 goroutine。
+func (r *RelaxedQueue[T]) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed.Load() {
+		return
+	}
+
+	// 先关闭所有通道再标记关闭，读到关闭标记的消费者可以确定不会再有元素进入任何通道。
+	for _, lane := range r.lanes {
+		lane.Close()
+	}
+	r.closed.Store(true)
+	close(r.done)
+	r.cond.Broadcast()
+}
+
+// Enqueue 方法把值 v 放入下一条通道（设置了分片键时为键对应的通道）的尾部。如果队列已关闭，返回 ErrQueueClosed。
+func (r *RelaxedQueue[T]) Enqueue(v T) error {
+	return r.EnqueueContext(context.Background(), v)
+}
+
+// EnqueueContext 方法与 Enqueue 相同。通道不限制容量，入队从不阻塞，因此 ctx 不会影响结果。
+func (r *RelaxedQueue[T]) EnqueueContext(ctx context.Context, v T) error {
+	lane := r.lane(v)
+	if err := lane.EnqueueContext(ctx, v); err != nil {
+		return err
+	}
+
+	// 元素已经计入通道的 Count()；消费者在检查 Count() 之前先计入 waiting，因此这里读到 0 时不会有消费者错过它。
+	if r.waiting.Load() > 0 {
+		r.lock.Lock()
+		r.cond.Signal()
+		r.lock.Unlock()
+	}
+	return nil
+}
+
+// TryEnqueue 方法与 Enqueue 相同，入队成功时返回 true，队列已关闭时返回 false。
+func (r *RelaxedQueue[T]) TryEnqueue(v T) bool {
+	return r.Enqueue(v) == nil
+}
+
+// lane 方法返回值 v 应当进入的通道。
+func (r *RelaxedQueue[T]) lane(v T) *NQueue[T] {
+	if r.key != nil {
+		return r.lanes[r.key(v)%uint64(len(r.lanes))]
+	}
+	return r.lanes[r.put.Add(1)%uint64(len(r.lanes))]
+}
+
+// Dequeue 方法是一个非阻塞的出队方法，从轮换的起点开始依次尝试各条通道。
+func (r *RelaxedQueue[T]) Dequeue() (t T, ok bool, isClose bool) {
+	isClose = r.closed.Load() // 先读取关闭状态，保证 isClose 为 true 且 ok 为 false 时所有通道确实已被取空。
+	start := r.take.Add(1)
+	for i := range uint64(len(r.lanes)) {
+		if t, ok, _ = r.lanes[(start+i)%uint64(len(r.lanes))].Dequeue(); ok {
+			return t, true, isClose
+		}
+	}
+	return t, false, isClose
+}
+
+// takeIf 方法实现 headTaker，从轮换的起点开始找到第一条非空通道，把它的头部元素交给 accept。
+func (r *RelaxedQueue[T]) takeIf(accept func(T) bool) bool {
+	start := r.take.Add(1)
+	for i := range uint64(len(r.lanes)) {
+		asked := false
+		taken := r.lanes[(start+i)%uint64(len(r.lanes))].takeIf(func(t T) bool {
+			asked = true
+			return accept(t)
+		})
+		if asked {
+			return taken // 只把一个元素交给 accept，被拒绝时不再尝试其他通道。
+		}
+	}
+	return false
+}
+
+// TryDequeue 方法是一个从不阻塞的出队方法，与 Dequeue 相同但不返回关闭状态；所有通道都为空时 ok 为 false。
+func (r *RelaxedQueue[T]) TryDequeue() (t T, ok bool) {
+	t, ok, _ = r.Dequeue()
+	return
+}
+
+// Peek 方法返回下一次 Dequeue 最可能取出的元素，即从当前出队起点开始第一条非空通道的头部元素，但不将其移除。
+// 通道之间没有顺序保证，并发出队时下一次 Dequeue 可能从其他通道取出元素。所有通道都为空时 ok 为 false。
+func (r *RelaxedQueue[T]) Peek() (t T, ok bool) {
+	start := r.take.Load() + 1
+	for i := range uint64(len(r.lanes)) {
+		if t, ok = r.lanes[(start+i)%uint64(len(r.lanes))].Peek(); ok {
+			return t, true
+		}
+	}
+	return t, false
+}
+
+// Drain 方法依次取出每条通道中当前的所有元素。每条通道分别加锁，取出期间入队的元素可能被包括在内，
+// 各条通道的元素依次排列，同一通道内保持入队顺序。所有通道都为空时返回 nil。
+func (r *RelaxedQueue[T]) Drain() (ts []T) {
+	for _, lane := range r.lanes {
+		ts = append(ts, lane.Drain()...)
+	}
+	return
+}
+
+// DequeueWait 方法是一个阻塞的出队方法，会一直等待直到任意一条通道有元素出队或队列关闭。
+func (r *RelaxedQueue[T]) DequeueWait() (t T, ok bool, isClose bool) {
+	t, ok, isClose, _ = r.DequeueContext(context.Background())
+	return
+}
+
+// DequeueContext 方法与 DequeueWait 相同，但在等待时会响应 ctx 的取消。
+// ctx 结束且所有通道都没有元素时返回 ctx.Err()，此时 ok 为 false。
+func (r *RelaxedQueue[T]) DequeueContext(ctx context.Context) (t T, ok bool, isClose bool, err error) {
+	for {
+		if t, ok, isClose = r.Dequeue(); ok || isClose {
+			return t, ok, isClose, nil
+		}
+
+		r.lock.Lock()
+		r.waiting.Add(1)
+		err = waitCond(ctx, r.cond, r.ready, (*sync.Cond).Wait)
+		r.waiting.Add(-1)
+		r.lock.Unlock()
+		if err != nil {
+			return t, false, r.closed.Load(), err
+		}
+	}
+}
+
+// ready 方法判断出队操作是否可以继续：任意一条通道非空或队列已关闭。
+func (r *RelaxedQueue[T]) ready() bool {
+	return r.Count() > 0 || r.closed.Load()
+}
+
+// DequeueFunc 方法不断出队元素并调用 fn 进行处理，直到 fn 返回 false 或队列关闭且为空。
+func (r *RelaxedQueue[T]) DequeueFunc(fn DequeueFunc[T]) (err error) {
+	for {
+		t, ok, isClose := r.DequeueWait()
+		if !ok {
+			return ErrQueueClosedEmpty
+		}
+
+		if !fn(t, isClose) {
+			return
+		}
+	}
+}
+
+// DequeueFuncContext 方法与 DequeueFunc 相同，但在 ctx 结束时停止出队并返回 ctx.Err()，队列本身不会被关闭。
+func (r *RelaxedQueue[T]) DequeueFuncContext(ctx context.Context, fn DequeueFunc[T]) (err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err // 每次出队前检查，队列中一直有元素时也能及时停止。
+		}
+
+		t, ok, isClose, err := r.DequeueContext(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrQueueClosedEmpty
+		}
+
+		if !fn(t, isClose) {
+			return nil
+		}
+	}
+}
+
+// Count 方法返回所有通道中元素数量的总和。各通道的数量分别读取，并发修改时结果只是近似值。
+func (r *RelaxedQueue[T]) Count() int64 {
+	var n int64
+	for _, lane := range r.lanes {
+		n += lane.Count()
+	}
+	return n
+}
+
+// Cap 方法返回队列的容量上限。通道不限制容量，总是返回 0。
+func (r *RelaxedQueue[T]) Cap() int64 {
+	return 0
+}
+
+// Status 方法用于获取队列的状态，true 表示队列处于打开状态。
+func (r *RelaxedQueue[T]) Status() bool {
+	return !r.closed.Load()
+}
+
+// IsClosed 方法判断队列是否已关闭，等价于 !Status()。
+func (r *RelaxedQueue[T]) IsClosed() bool {
+	return r.closed.Load()
+}
+
+// Done 方法返回一个在队列关闭时被关闭的通道。
+func (r *RelaxedQueue[T]) Done() <-chan struct{} {
+	return r.done
+}
@@ -0,0 +1,162 @@
+package nqueue
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// go test -run TestRelaxedQueue -v
+func TestRelaxedQueue(t *testing.T) {
+	const lanes, producers, perProducer = 4, 8, 10000
+	q := NewRelaxedQueue[[2]int](lanes)
+
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.Enqueue([2]int{p, i})
+			}
+		}()
+	}
+
+	// 每个元素恰好出队一次。
+	var mu sync.Mutex
+	seen := make(map[[2]int]bool)
+	var consumers sync.WaitGroup
+	consumers.Add(lanes)
+	for c := 0; c < lanes; c++ {
+		go func() {
+			defer consumers.Done()
+			q.DequeueFunc(func(v [2]int, isClose bool) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				if seen[v] {
+					t.Errorf("%v dequeued twice", v)
+				}
+				seen[v] = true
+				return true
+			})
+		}()
+	}
+
+	wg.Wait()
+	q.Close()
+	consumers.Wait()
+	if len(seen) != producers*perProducer || q.Count() != 0 {
+		t.Fatalf("dequeued %d of %d items, Count() = %d", len(seen), producers*perProducer, q.Count())
+	}
+	if err := q.Enqueue([2]int{}); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Enqueue() after Close = %v, want ErrQueueClosed", err)
+	}
+	if _, ok, isClose := q.DequeueWait(); ok || !isClose {
+		t.Fatalf("DequeueWait() on closed queue = %v, %v, want false, true", ok, isClose)
+	}
+}
+
+// go test -run TestRelaxedQueueLaneOrder -v
+func TestRelaxedQueueLaneOrder(t *testing.T) {
+	const lanes = 3
+	q := NewRelaxedQueue[int](lanes)
+	for i := 0; i < 30; i++ {
+		q.Enqueue(i)
+	}
+	q.Close()
+
+	// 单个生产者按轮询写入各条通道，i%lanes 相同的元素进入同一条通道，它们之间保持入队顺序。
+	last := []int{-1, -1, -1}
+	q.DequeueFunc(func(v int, isClose bool) bool {
+		lane := v % lanes
+		if v <= last[lane] {
+			t.Fatalf("lane %d: %d dequeued after %d", lane, v, last[lane])
+		}
+		last[lane] = v
+		return true
+	})
+}
+
+// go test -run TestShardedQueue -v
+func TestShardedQueue(t *testing.T) {
+	const shards, producers, perProducer = 4, 8, 1000
+	var q Queue[[2]int] = NewShardedQueue(shards, func(v [2]int) uint64 { return uint64(v[0]) })
+
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.Enqueue([2]int{p, i})
+			}
+		}()
+	}
+
+	// 相同键的元素进入同一个分片，即使分散到多个消费者，单个消费者看到的同一生产者的元素也保持递增。
+	var mu sync.Mutex
+	total := 0
+	var consumers sync.WaitGroup
+	consumers.Add(shards)
+	for c := 0; c < shards; c++ {
+		go func() {
+			defer consumers.Done()
+			last := make(map[int]int)
+			q.DequeueFunc(func(v [2]int, isClose bool) bool {
+				if prev, ok := last[v[0]]; ok && v[1] <= prev {
+					t.Errorf("producer %d: %d dequeued after %d", v[0], v[1], prev)
+				}
+				last[v[0]] = v[1]
+				mu.Lock()
+				total++
+				mu.Unlock()
+				return true
+			})
+		}()
+	}
+
+	wg.Wait()
+	q.Close()
+	consumers.Wait()
+	if total != producers*perProducer {
+		t.Fatalf("dequeued %d of %d items", total, producers*perProducer)
+	}
+
+	// 所有元素都在同一个分片时，其他分片上的出队也能取到它们。
+	q = NewShardedQueue(shards, func([2]int) uint64 { return 0 })
+	for i := 0; i < shards*2; i++ {
+		q.Enqueue([2]int{0, i})
+	}
+	for i := 0; i < shards*2; i++ {
+		if v, ok, _ := q.Dequeue(); !ok || v[1] != i {
+			t.Fatalf("Dequeue() = %v, %v, want [0 %d], true", v, ok, i)
+		}
+	}
+}
+
+// go test -bench BenchmarkRelaxedOrdering -run ^$ -cpu 8
+func BenchmarkRelaxedOrdering(b *testing.B) {
+	// 所有 goroutine 并行入队，同时由固定数量的消费者取出，模拟大量生产者争用同一个队列的场景。
+	run := func(b *testing.B, q Queue[int]) {
+		var consumers sync.WaitGroup
+		consumers.Add(4)
+		for i := 0; i < 4; i++ {
+			go func() {
+				defer consumers.Done()
+				q.DequeueFunc(func(int, bool) bool { return true })
+			}()
+		}
+
+		b.SetParallelism(16)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				q.Enqueue(1)
+			}
+		})
+		q.Close()
+		consumers.Wait()
+	}
+
+	b.Run("strict", func(b *testing.B) { run(b, NewNQueue[int]()) })
+	b.Run("relaxed", func(b *testing.B) { run(b, NewRelaxedQueue[int](0)) })
+}
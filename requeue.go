@@ -0,0 +1,116 @@
+package nqueue
+
+import "time"
+
+// RequeuePolicy 决定通过 DequeueAck、DequeueAckMeta 或 DequeueBatchAck 的确认回调放回的元素落在队列的什么位置，
+// 由 WithRequeuePolicy 设置，默认为 RequeueHead。
+type RequeuePolicy struct {
+	kind  requeueKind
+	delay time.Duration
+}
+
+type requeueKind int
+
+const (
+	requeueHead requeueKind = iota
+	requeueTail
+	requeueDelay
+)
+
+var (
+	// RequeueHead 表示放回队列头部，下一次出队时立即重新投递，这是默认行为。
+	// 反复失败的元素会一直占据头部，其他元素要等它被确认后才能出队。
+	RequeueHead = RequeuePolicy{kind: requeueHead}
+	// RequeueTail 表示放回队列尾部，排在已经入队的元素之后重新投递，失败的元素不会阻塞新的元素。
+	RequeueTail = RequeuePolicy{kind: requeueTail}
+)
+
+// RequeueDelay 函数返回一个延迟放回的策略：元素在 d 之后才放回队列尾部，在此之前不可出队，
+// 但仍计入 InFlight() 和 Delayed()，WaitDrain 和 Drained 会等待它重新入队并被确认。d 小于等于 0 时等同于 RequeueTail。
+// 延迟与 EnqueueAfter 共用同一套定时机制，队列关闭时尚未到期的元素立即放回，关闭后才要求重新投递的元素不再延迟。
+func RequeueDelay(d time.Duration) RequeuePolicy {
+	if d <= 0 {
+		return RequeueTail
+	}
+	return RequeuePolicy{kind: requeueDelay, delay: d}
+}
+
+// requeue 方法把以 first 开头、以 nil 结尾的未确认节点按 WithRequeuePolicy 设置的策略按原顺序放回队列，
+// 并增加它们的投递次数。重新投递不受容量上限和关闭状态的限制，因为这些元素此前已经占用过队列的位置。
+// 投递次数达到 WithMaxAttempts 上限的元素以 DropMaxAttempts 丢弃。调用方需持有 recvLock。
+func (q *NQueue[T]) requeue(first *node[T]) {
+	var kept, last *node[T]
+	for n := first; n != nil; {
+		next := n.next
+		n.next = nil
+		m := n.metadata()
+		if m.attempts++; q.maxTries > 0 && m.attempts >= q.maxTries {
+			q.drop(n.value, DropMaxAttempts)
+			q.recycle(n)
+		} else if kept == nil {
+			kept, last = n, n
+		} else {
+			last.next, last = n, n
+		}
+		n = next
+	}
+	if kept == nil {
+		if q.isEmpty() {
+			q.emptied()
+		}
+		return
+	}
+	first = kept
+
+	kind := q.requeueAt.kind
+	if kind == requeueDelay && !q.status {
+		kind = requeueTail // 队列已关闭，与关闭时尚未到期的元素一样立即放回，不再推迟取空。
+	}
+	switch kind {
+	case requeueTail:
+		q.restamp(first)
+		q.pushBack(first)
+	case requeueDelay:
+		at := time.Now().Add(q.requeueAt.delay).UnixNano()
+		for n := first; n != nil; {
+			next := n.next
+			n.next = nil
+			q.inflight++ // 延迟期间仍视为未确认，到期放回时减去。
+			q.delay(n, at, true)
+			n = next
+		}
+	default:
+		q.restamp(first)
+		q.pushFrontChain(first)
+	}
+}
+
+// restamp 方法在开启 WithTimestamps 时把链表中节点的入队时间更新为当前时间，重新投递的元素从放回时重新计时。
+func (q *NQueue[T]) restamp(first *node[T]) {
+	if !q.stamped {
+		return
+	}
+	now := time.Now().UnixNano()
+	for n := first; n != nil; n = n.next {
+		n.metadata().enqueuedAt = now
+	}
+}
+
+// pushBack 方法把以 first 开头、以 nil 结尾的链表按原顺序放回队列尾部，不检查关闭状态和容量上限。
+// 开启 WithSpill 时逐个经过 pushNode，保证放回的元素排在已溢出到磁盘的元素之后；
+// 写入磁盘失败的元素被丢弃并以 DropSpillFailed 报告。调用方需持有 recvLock。
+func (q *NQueue[T]) pushBack(first *node[T]) {
+	if q.spill == nil {
+		q.pushChain(first)
+		return
+	}
+
+	for n := first; n != nil; {
+		next, v := n.next, n.value
+		n.next = nil
+		if err := q.pushNode(n); err != nil {
+			q.drop(v, DropSpillFailed)
+		}
+		n = next
+	}
+}
@@ -0,0 +1,81 @@
+package nqueue
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// go test -run TestWithRequeuePolicy -v
+func TestWithRequeuePolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy RequeuePolicy
+		want   []int // 1 和 2 处理失败后的出队顺序。
+	}{
+		{"head", RequeueHead, []int{1, 2, 3, 4}},
+		{"tail", RequeueTail, []int{3, 4, 1, 2}},
+		{"delay", RequeueDelay(50 * time.Millisecond), []int{3, 4, 1, 2}},
+	}
+	for _, c := range cases {
+		q := NewNQueue(WithRequeuePolicy[int](c.policy))
+		for i := 1; i <= 4; i++ {
+			q.Enqueue(i)
+		}
+		_, ack, _ := q.DequeueBatchAck(2)
+		ack(0)
+
+		var got []int
+		for len(got) < 4 {
+			v, attempt, ack, _ := q.DequeueAckMeta()
+			want := 1
+			if v <= 2 {
+				want = 2
+			}
+			if attempt != want {
+				t.Fatalf("%s: attempt of %d = %d, want %d", c.name, v, attempt, want)
+			}
+			ack(false)
+			got = append(got, v)
+		}
+		if !slices.Equal(got, c.want) {
+			t.Fatalf("%s: redelivery order = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// go test -run TestRequeueDelay -v
+func TestRequeueDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	q := NewNQueue(WithRequeuePolicy[int](RequeueDelay(delay)))
+	q.Enqueue(1)
+
+	start := time.Now()
+	_, ack, _ := q.DequeueAck()
+	ack(true)
+
+	// 延迟期间元素不可见，但仍计入 InFlight()。
+	if _, ok, _ := q.Dequeue(); ok || q.Count() != 0 || q.InFlight() != 1 {
+		t.Fatalf("during delay: ok = %v, Count() = %d, InFlight() = %d, want false, 0, 1", ok, q.Count(), q.InFlight())
+	}
+
+	v, ok, _ := q.DequeueWait()
+	if !ok || v != 1 || time.Since(start) < delay {
+		t.Fatalf("DequeueWait() = %d, %v after %v, want 1, true after %v", v, ok, time.Since(start), delay)
+	}
+	if q.InFlight() != 0 {
+		t.Fatalf("InFlight() = %d after redelivery, want 0", q.InFlight())
+	}
+
+	// 关闭后要求重新投递的元素立即放回，不会推迟取空。
+	q.Enqueue(2)
+	_, ack, _ = q.DequeueAck()
+	q.Close()
+	ack(true)
+	if q.Delayed() != 0 || q.Count() != 1 || q.InFlight() != 0 {
+		t.Fatalf("requeue after Close: Delayed() = %d, Count() = %d, InFlight() = %d, want 0, 1, 0", q.Delayed(), q.Count(), q.InFlight())
+	}
+	if v, ok, _ := q.Dequeue(); !ok || v != 2 || !q.IsDrained() {
+		t.Fatalf("Dequeue() after Close = %d, %v, IsDrained() = %v, want 2, true, true", v, ok, q.IsDrained())
+	}
+}
@@ -0,0 +1,222 @@
+//go:build nqueue_deterministic
+
+package nqueue
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Scheduler 是用于测试的确定性调度器，只在使用 nqueue_deterministic 构建标签时存在：
+//
+//	go test -tags nqueue_deterministic ./...
+//
+// 通过 Go 注册的参与者 goroutine 在 Run 期间轮流执行，任意时刻只有一个参与者在运行。
+// 参与者每次调用绑定了该调度器的 NQueue 的入队、出队、确认或关闭方法（包括 QueueCursor 摘取和归还元素）时都会让出执行权，
+// 阻塞等待元素或空位时也会让出执行权；下一个运行的参与者由 seed 决定的随机数选出。
+// 因此只要参与者的行为只依赖队列，同一个 seed 总是得到完全相同的执行顺序和出队顺序，
+// 不同的 seed 则会覆盖不同的交错方式。
+//
+// 参与者不能阻塞在调度器不知道的地方（其他通道、锁或未绑定的队列），否则所有参与者都会停住；
+// 队列的异步回调（WithOnEmpty 等）和 WithCloseOnContext 不受调度器控制。
+// 队列在持有自己的锁时调用另一个队列的方法（转发到 WithDeadLetter 的死信队列、Transfer 放入 dst）不是调度点。
+// 所有参与者都在等待而没有参与者能继续执行时，Run 会 panic 报告死锁，等待中的参与者不会再被调度。
+type Scheduler struct {
+	lock    sync.Mutex
+	cond    *sync.Cond // 参与者等待执行权的条件变量。
+	rng     *rand.Rand // 选择下一个参与者的随机数生成器。
+	procs   []*proc    // 按注册顺序排列的存活参与者。
+	current *proc      // 当前持有执行权的参与者。
+	running bool       // Run 是否正在执行。
+	stuck   bool       // 是否所有参与者都在等待而无法继续执行。
+	exited  chan struct{}
+}
+
+// proc 是调度器中的一个参与者。
+type proc struct {
+	fn    func()
+	stale bool // 阻塞后还没有其他参与者取得进展，再次调度它也不会有结果。
+}
+
+// schedulers 记录每个队列绑定的调度器，键为队列编号。队列关闭且取空后由 unbind 删除。
+var schedulers sync.Map
+
+// nested 是持有某个队列的锁时调用其他队列方法的嵌套深度，大于 0 时调度点不让出执行权：
+// 持有锁时让出执行权，下一个参与者会真正阻塞在这把锁上，调度器随之停住而无法报告死锁。
+var nested atomic.Int32
+
+// enterNested 函数标记接下来的队列调用发生在持有另一个队列的锁时，必须与 exitNested 成对调用。
+func enterNested() {
+	nested.Add(1)
+}
+
+// exitNested 函数结束 enterNested 标记的嵌套调用。
+func exitNested() {
+	nested.Add(-1)
+}
+
+// NewScheduler 函数创建一个以 seed 为随机种子的确定性调度器。
+func NewScheduler(seed int64) *Scheduler {
+	s := &Scheduler{rng: rand.New(rand.NewSource(seed))}
+	s.cond = sync.NewCond(&s.lock)
+	return s
+}
+
+// WithDeterministicScheduler 选项把队列绑定到调度器 s。只在使用 nqueue_deterministic 构建标签时存在。
+// 队列关闭且取空后绑定自动解除，之后的调用不再是调度点；Reset 不会恢复绑定。
+func WithDeterministicScheduler[T any](s *Scheduler) Option[T] {
+	return func(q *NQueue[T]) {
+		schedulers.Store(q.id, s)
+	}
+}
+
+// Go 方法注册一个参与者，它会在 Run 被调用后开始执行。必须在 Run 之前调用。
+func (s *Scheduler) Go(fn func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.procs = append(s.procs, &proc{fn: fn})
+}
+
+// Run 方法按确定的顺序执行所有已注册的参与者，直到它们全部返回。
+// 参与者不在运行期间，绑定的队列与普通队列的行为相同。
+func (s *Scheduler) Run() {
+	s.lock.Lock()
+	if len(s.procs) == 0 {
+		s.lock.Unlock()
+		return
+	}
+	s.running = true
+	s.exited = make(chan struct{})
+	for _, p := range s.procs {
+		go s.start(p)
+	}
+	s.handoff()
+	s.lock.Unlock()
+
+	<-s.exited
+	if s.stuck {
+		panic("nqueue: deterministic scheduler deadlock: all participants are blocked")
+	}
+}
+
+// start 方法在参与者取得执行权后运行它，返回后把执行权交给下一个参与者。
+func (s *Scheduler) start(p *proc) {
+	s.lock.Lock()
+	for s.current != p {
+		s.cond.Wait()
+	}
+	s.lock.Unlock()
+
+	p.fn()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.procs = removeProc(s.procs, p)
+	if len(s.procs) == 0 {
+		s.running, s.current = false, nil
+		close(s.exited)
+		return
+	}
+	s.progress()
+	s.handoff()
+}
+
+// yield 方法让当前参与者在调度点让出执行权，并等待再次被选中。
+func (s *Scheduler) yield() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.running {
+		return
+	}
+
+	p := s.current
+	s.progress() // 到达调度点说明当前参与者取得了进展，之前阻塞的参与者可能已经可以继续。
+	s.handoff()
+	for s.current != p {
+		s.cond.Wait()
+	}
+}
+
+// wait 方法代替队列条件变量 cond 上的一次等待：释放 cond.L，把执行权交给其他参与者，
+// 再次被选中后重新持有 cond.L 并返回。参与者并不真正阻塞在 cond 上，返回后由调用方重新检查条件，
+// 条件仍不满足时会再次等待。调用方需持有 cond.L。
+func (s *Scheduler) wait(cond *sync.Cond) {
+	s.lock.Lock()
+	if !s.running {
+		s.lock.Unlock()
+		cond.Wait()
+		return
+	}
+
+	p := s.current
+	p.stale = true // 在其他参与者取得进展之前，它的条件不会改变。
+	cond.L.Unlock()
+	s.handoff()
+	for s.current != p {
+		s.cond.Wait()
+	}
+	s.lock.Unlock()
+	cond.L.Lock()
+}
+
+// progress 方法清除所有参与者的阻塞标记。调用方需持有 lock。
+func (s *Scheduler) progress() {
+	for _, p := range s.procs {
+		p.stale = false
+	}
+}
+
+// handoff 方法从可以运行的参与者中随机选出下一个并交出执行权。调用方需持有 lock。
+// 没有可以运行的参与者时通知 Run 报告死锁。
+func (s *Scheduler) handoff() {
+	var runnable []*proc
+	for _, p := range s.procs {
+		if !p.stale {
+			runnable = append(runnable, p)
+		}
+	}
+	if len(runnable) == 0 {
+		s.running, s.stuck, s.current = false, true, nil
+		close(s.exited)
+		return
+	}
+
+	s.current = runnable[s.rng.Intn(len(runnable))]
+	s.cond.Broadcast()
+}
+
+// removeProc 函数从 procs 中移除 p 并保持其余参与者的顺序。
+func removeProc(procs []*proc, p *proc) []*proc {
+	for i, q := range procs {
+		if q == p {
+			return append(procs[:i], procs[i+1:]...)
+		}
+	}
+	return procs
+}
+
+// schedPoint 方法是确定性调度的调度点：队列绑定了正在运行的调度器时，当前参与者在这里让出执行权。
+func (q *NQueue[T]) schedPoint() {
+	if nested.Load() > 0 {
+		return
+	}
+	if s, ok := schedulers.Load(q.id); ok {
+		s.(*Scheduler).yield()
+	}
+}
+
+// unbind 方法解除队列与调度器的绑定，在队列进入终止状态时调用，避免长时间运行的测试不断积累已经不用的队列。
+func (q *NQueue[T]) unbind() {
+	schedulers.Delete(q.id)
+}
+
+// wait 方法在 cond 上阻塞等待一次；队列绑定了正在运行的调度器时，等待期间把执行权交给其他参与者。
+// 调用方需持有 cond.L。
+func (q *NQueue[T]) wait(cond *sync.Cond) {
+	if s, ok := schedulers.Load(q.id); ok && nested.Load() == 0 {
+		s.(*Scheduler).wait(cond)
+		return
+	}
+	cond.Wait()
+}
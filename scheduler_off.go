@@ -0,0 +1,23 @@
+//go:build !nqueue_deterministic
+
+package nqueue
+
+import "sync"
+
+// schedPoint 方法是确定性调度的调度点，只在使用 nqueue_deterministic 构建标签时生效，参见 Scheduler。
+// 正常构建时它是空方法，会被编译器内联消除。
+func (q *NQueue[T]) schedPoint() {}
+
+// enterNested 函数标记接下来的队列调用发生在持有另一个队列的锁时，正常构建时是空函数。
+func enterNested() {}
+
+// exitNested 函数结束 enterNested 标记的嵌套调用，正常构建时是空函数。
+func exitNested() {}
+
+// unbind 方法解除队列与调度器的绑定，正常构建时是空方法。
+func (q *NQueue[T]) unbind() {}
+
+// wait 方法在 cond 上阻塞等待一次。调用方需持有 cond.L。
+func (q *NQueue[T]) wait(cond *sync.Cond) {
+	cond.Wait()
+}
@@ -0,0 +1,132 @@
+//go:build nqueue_deterministic
+
+package nqueue
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// go test -tags nqueue_deterministic -run TestDeterministicScheduler -v
+func TestDeterministicScheduler(t *testing.T) {
+	// 两个生产者和两个消费者交错执行，记录每一次出队由哪个消费者完成。
+	run := func(seed int64) []string {
+		s := NewScheduler(seed)
+		q := NewNQueueWithCap[int](2, WithDeterministicScheduler[int](s))
+
+		var log []string
+		for p := 0; p < 2; p++ {
+			s.Go(func() {
+				for i := 0; i < 5; i++ {
+					q.Enqueue(p*10 + i)
+				}
+			})
+		}
+		for c := 0; c < 2; c++ {
+			s.Go(func() {
+				for i := 0; i < 5; i++ {
+					v, _, _ := q.DequeueWait()
+					log = append(log, fmt.Sprintf("c%d:%d", c, v))
+				}
+			})
+		}
+		s.Run()
+		return log
+	}
+
+	want := run(1)
+	if len(want) != 10 {
+		t.Fatalf("got %d dequeues, want 10: %v", len(want), want)
+	}
+	for i := 0; i < 10; i++ {
+		if got := run(1); !slices.Equal(got, want) {
+			t.Fatalf("run %d with the same seed differs:\n got %v\nwant %v", i, got, want)
+		}
+	}
+
+	// 不同的 seed 产生不同的交错。
+	differs := false
+	for seed := int64(2); seed < 20 && !differs; seed++ {
+		differs = !slices.Equal(run(seed), want)
+	}
+	if !differs {
+		t.Fatal("all seeds produced the same interleaving")
+	}
+}
+
+// go test -tags nqueue_deterministic -run TestDeterministicSchedulerDeadlock -v
+func TestDeterministicSchedulerDeadlock(t *testing.T) {
+	s := NewScheduler(1)
+	q := NewNQueue[int](WithDeterministicScheduler[int](s))
+
+	// 唯一的参与者等待一个永远不会入队的元素，调度器报告死锁。
+	s.Go(func() { q.DequeueWait() })
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Run() did not report the deadlock")
+		}
+	}()
+	s.Run()
+}
+
+// go test -tags nqueue_deterministic -run TestDeterministicSchedulerUnbind -v
+func TestDeterministicSchedulerUnbind(t *testing.T) {
+	s := NewScheduler(1)
+	q := NewNQueue[int](WithDeterministicScheduler[int](s))
+	empty := NewNQueue[int](WithDeterministicScheduler[int](s))
+	q.Enqueue(1)
+
+	// 关闭时仍有元素的队列在取空后解除绑定，关闭时已为空的队列立即解除绑定。
+	q.Close()
+	empty.Close()
+	if _, ok := schedulers.Load(q.id); !ok {
+		t.Fatal("queue unbound before it was drained")
+	}
+	if _, ok := schedulers.Load(empty.id); ok {
+		t.Fatal("queue closed while empty still bound")
+	}
+	q.Dequeue()
+	if _, ok := schedulers.Load(q.id); ok {
+		t.Fatal("drained queue still bound")
+	}
+}
+
+// go test -tags nqueue_deterministic -run TestDeterministicSchedulerDeadLetter -v
+func TestDeterministicSchedulerDeadLetter(t *testing.T) {
+	// 死信队列与源队列绑定同一个调度器：持有源队列的锁转发时不能让出执行权，否则消费者会真正阻塞在锁上。
+	for seed := int64(0); seed < 20; seed++ {
+		s := NewScheduler(seed)
+		dlq := NewNQueue[int](WithDeterministicScheduler[int](s))
+		q := NewNQueueWithCap(1, WithOverflow[int](OverflowDropNewest), WithDeadLetter[int](dlq), WithDeterministicScheduler[int](s))
+
+		got := 0
+		s.Go(func() {
+			for i := 0; i < 5; i++ {
+				q.Enqueue(i)
+			}
+		})
+		s.Go(func() {
+			for i := 0; i < 5; i++ {
+				if _, ok, _ := q.Dequeue(); ok {
+					got++
+				}
+			}
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.Run()
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("seed %d: Run() did not return", seed)
+		}
+		if total := got + int(dlq.Count()) + int(q.Count()); total != 5 {
+			t.Fatalf("seed %d: dequeued %d, dead-lettered %d, left %d, want 5 in total", seed, got, dlq.Count(), q.Count())
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package nqueue
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// spillHeader 是磁盘上每条记录的头部长度：4 字节的数据长度、8 字节的元素大小和 8 字节的入队时间。
+const spillHeader = 20
+
+// spill 是 WithSpill 使用的磁盘溢出区，按先进先出的顺序保存超出内存上限的元素。
+// 所有方法都由持有 recvLock 的队列调用。
+type spill[T any] struct {
+	dir      string                  // 溢出文件所在的目录。
+	memLimit int64                   // 内存中最多保留的元素数量。
+	encode   func(T) ([]byte, error) // 元素的序列化函数。
+	decode   func([]byte) (T, error) // 元素的反序列化函数。
+	file     *os.File                // 溢出文件，第一次溢出时创建。
+	roff     int64                   // 下一条待读取记录的偏移。
+	woff     int64                   // 下一条记录的写入偏移。
+	n        int64                   // 磁盘上的元素数量。
+	size     int64                   // 磁盘上元素的总字节数，由 sizeOf 计算，用于维护 ByteLen。
+	err      error                   // 读回元素时遇到的第一个错误。
+	buf      []byte                  // 读写记录的缓冲区。
+}
+
+// write 方法把节点 n 的值追加到溢出文件末尾，size 为 sizeOf 计算的元素大小。
+func (s *spill[T]) write(n *node[T], size int64) error {
+	data, err := s.encode(n.value)
+	if err != nil {
+		return err
+	}
+	if s.file == nil {
+		if s.file, err = os.CreateTemp(s.dir, "nqueue-*.spill"); err != nil {
+			return err
+		}
+	}
+
+	s.buf = binary.LittleEndian.AppendUint32(s.buf[:0], uint32(len(data)))
+	s.buf = binary.LittleEndian.AppendUint64(s.buf, uint64(size))
+	s.buf = binary.LittleEndian.AppendUint64(s.buf, uint64(n.enqueuedAt()))
+	s.buf = append(s.buf, data...)
+	if _, err = s.file.WriteAt(s.buf, s.woff); err != nil {
+		return err
+	}
+	s.woff += int64(len(s.buf))
+	s.n++
+	s.size += size
+	return nil
+}
+
+// read 方法使用缓冲区 buf 读取并解码偏移 off 处的记录，返回元素、元素大小、入队时间和下一条记录的偏移。
+// 无法读取记录头部时 next 等于 off。
+func (s *spill[T]) read(off int64, buf *[]byte) (v T, size, enqueuedAt, next int64, err error) {
+	var header [spillHeader]byte
+	if _, err = s.file.ReadAt(header[:], off); err != nil {
+		return v, 0, 0, off, err
+	}
+	length := int64(binary.LittleEndian.Uint32(header[:4]))
+	size = int64(binary.LittleEndian.Uint64(header[4:12]))
+	enqueuedAt = int64(binary.LittleEndian.Uint64(header[12:]))
+	next = off + spillHeader + length
+
+	if int64(cap(*buf)) < length {
+		*buf = make([]byte, length)
+	}
+	data := (*buf)[:length]
+	if _, err = s.file.ReadAt(data, off+spillHeader); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return v, size, 0, next, err
+	}
+	v, err = s.decode(data)
+	return v, size, enqueuedAt, next, err
+}
+
+// pop 方法取出溢出文件头部的记录，dropped 和 droppedSize 为因读取失败而丢弃的元素数量和总字节数。
+// 解码失败只丢弃这一条记录；连记录头部都无法读取时剩余数据已不可信，磁盘上的元素全部丢弃。
+func (s *spill[T]) pop() (v T, enqueuedAt, dropped, droppedSize int64, err error) {
+	v, size, enqueuedAt, next, err := s.read(s.roff, &s.buf)
+	switch {
+	case next == s.roff:
+		dropped, droppedSize = s.n, s.size
+		s.n, s.size = 0, 0
+	case err != nil:
+		dropped, droppedSize = 1, size
+		s.n, s.size, s.roff = s.n-1, s.size-size, next
+	default:
+		s.n, s.size, s.roff = s.n-1, s.size-size, next
+	}
+
+	if s.n == 0 {
+		s.roff, s.woff = 0, 0 // 磁盘上的元素已全部读回，从头复用文件。
+		if terr := s.file.Truncate(0); err == nil {
+			err = terr
+		}
+	}
+	return v, enqueuedAt, dropped, droppedSize, err
+}
+
+// each 方法按先进先出的顺序对磁盘上最多 max 个可以解码的元素调用 fn，不会移除元素。
+// 它只读取文件，可以在持有读锁时与其他读取者并发调用。
+func (s *spill[T]) each(max int, fn func(v T)) {
+	var buf []byte
+	for off, i := s.roff, int64(0); i < s.n && max > 0; i++ {
+		v, _, _, next, err := s.read(off, &buf)
+		if next == off {
+			return
+		}
+		if err == nil {
+			fn(v)
+			max--
+		}
+		off = next
+	}
+}
+
+// remove 方法关闭并删除溢出文件。
+func (s *spill[T]) remove() {
+	if s.file == nil {
+		return
+	}
+	s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = nil
+}
+
+// spillNode 方法在开启 WithSpill 且内存中的元素已达到上限时，把节点 n 写入磁盘并回收节点。
+// 磁盘上已有元素时新元素也必须写入磁盘，以保证先进先出。spilled 为 false 且没有错误时，节点应链接到内存中。
+// 调用方需持有 recvLock。
+func (q *NQueue[T]) spillNode(n *node[T]) (spilled bool, err error) {
+	s := q.spill
+	if s == nil || (s.n == 0 && q.count.Load() < s.memLimit) {
+		return false, nil
+	}
+
+	var size int64
+	if q.sizeOf != nil {
+		size = int64(q.sizeOf(n.value))
+	}
+	if err = s.write(n, size); err == nil {
+		if q.sizeOf != nil {
+			q.bytes.Add(size)
+		}
+		q.added(1)
+	}
+	q.recycle(n)
+	return err == nil, err
+}
+
+// refill 方法在内存中的元素被取走后，从磁盘按顺序读回元素，直到内存中的元素重新达到上限或磁盘为空。
+// 读取失败的元素会被丢弃并以 DropSpillFailed 报告，第一个错误可以通过 SpillErr 获得。调用方需持有 recvLock。
+func (q *NQueue[T]) refill() {
+	s := q.spill
+	if s == nil {
+		return
+	}
+
+	for s.n > 0 && q.count.Load()-s.n < s.memLimit {
+		v, enqueuedAt, dropped, droppedSize, err := s.pop()
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		if dropped > 0 {
+			if q.sizeOf != nil {
+				q.bytes.Add(-droppedSize)
+			}
+			q.removed(dropped)
+			for range dropped {
+				q.drop(q.zeroValue, DropSpillFailed)
+			}
+			continue
+		}
+
+		n := q.newNode(v)
+		if enqueuedAt != 0 {
+			n.metadata().enqueuedAt = enqueuedAt // 保留元素最初的入队时间。
+		}
+		q.linkNode(n)
+	}
+	if s.n == 0 && !q.status {
+		s.remove() // 队列已关闭且不会再有元素溢出，删除溢出文件。
+	}
+}
+
+// memRoom 方法返回还能直接链接到内存中的元素数量，limited 为 false 表示未开启 WithSpill、不受限制。
+// 磁盘上已有元素时返回 0，新元素必须排在它们之后。调用方需持有 recvLock。
+func (q *NQueue[T]) memRoom() (room int64, limited bool) {
+	s := q.spill
+	if s == nil {
+		return 0, false
+	}
+	if s.n > 0 {
+		return 0, true
+	}
+	return max(s.memLimit-q.count.Load(), 0), true
+}
+
+// spilled 方法判断是否有元素溢出到磁盘。调用方需持有 recvLock。
+func (q *NQueue[T]) spilled() bool {
+	return q.spill != nil && q.spill.n > 0
+}
+
+// SpillLen 方法返回当前保存在磁盘上的元素数量，未开启 WithSpill 时总是返回 0。
+// 这些元素已计入 Count()。
+func (q *NQueue[T]) SpillLen() int64 {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	if q.spill == nil {
+		return 0
+	}
+	return q.spill.n
+}
+
+// SpillErr 方法返回从磁盘读回元素时遇到的第一个错误，没有错误或未开启 WithSpill 时返回 nil。
+// 出错的元素会被丢弃，队列继续读取后面的元素。写入磁盘的错误由 Enqueue 直接返回。
+func (q *NQueue[T]) SpillErr() error {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	if q.spill == nil {
+		return nil
+	}
+	return q.spill.err
+}
@@ -0,0 +1,114 @@
+package nqueue
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func encodeInt(v int) ([]byte, error) { return strconv.AppendInt(nil, int64(v), 10), nil }
+
+func decodeInt(b []byte) (int, error) { return strconv.Atoi(string(b)) }
+
+// go test -run TestWithSpill -v
+func TestWithSpill(t *testing.T) {
+	dir := t.TempDir()
+	q := NewNQueue[int](WithSpill(dir, 4, encodeInt, decodeInt), WithSizeOf(func(int) int { return 1 }))
+
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+	if q.Count() != 10 || q.SpillLen() != 6 || q.ByteLen() != 10 {
+		t.Fatalf("Count() = %d, SpillLen() = %d, ByteLen() = %d, want 10, 6, 10", q.Count(), q.SpillLen(), q.ByteLen())
+	}
+	if got := q.Snapshot(); !slices.Equal(got, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Fatalf("Snapshot() = %v, want 0..9", got)
+	}
+
+	// 在内存和磁盘的边界附近交替入队和出队，出队顺序必须与入队顺序一致。
+	next, want := 10, 0
+	for round := 0; round < 2000; round++ {
+		for i := rand.Intn(8); i > 0; i-- {
+			q.Enqueue(next)
+			next++
+		}
+		for i := rand.Intn(8); i > 0; i-- {
+			v, ok, _ := q.Dequeue()
+			if !ok {
+				break
+			}
+			if v != want {
+				t.Fatalf("round %d: Dequeue() = %d, want %d", round, v, want)
+			}
+			want++
+		}
+		if q.Count() != int64(next-want) || q.ByteLen() != int64(next-want) {
+			t.Fatalf("round %d: Count() = %d, ByteLen() = %d, want %d", round, q.Count(), q.ByteLen(), next-want)
+		}
+	}
+
+	q.Close()
+	q.DequeueFunc(func(v int, isClose bool) bool {
+		if v != want {
+			t.Fatalf("DequeueFunc() got %d, want %d", v, want)
+		}
+		want++
+		return true
+	})
+	if want != next || q.SpillLen() != 0 || q.SpillErr() != nil {
+		t.Fatalf("drained %d of %d, SpillLen() = %d, SpillErr() = %v", want, next, q.SpillLen(), q.SpillErr())
+	}
+
+	// 队列关闭并取空后溢出文件被删除。
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("spill dir still has %d files after drain", len(entries))
+	}
+}
+
+// go test -run TestWithSpillErrors -v
+func TestWithSpillErrors(t *testing.T) {
+	errEncode := errors.New("encode")
+	encode := func(v int) ([]byte, error) {
+		if v < 0 {
+			return nil, errEncode
+		}
+		return encodeInt(v)
+	}
+	// 7 可以写入磁盘，但读回时解码失败。
+	decode := func(b []byte) (int, error) {
+		v, err := decodeInt(b)
+		if v == 7 {
+			return 0, strconv.ErrSyntax
+		}
+		return v, err
+	}
+
+	q := NewNQueue[int](WithSpill(t.TempDir(), 2, encode, decode))
+	for _, v := range []int{1, 2, 7, 8} {
+		q.Enqueue(v)
+	}
+
+	// 写入失败的元素不会入队。
+	if err := q.Enqueue(-1); !errors.Is(err, errEncode) {
+		t.Fatalf("Enqueue(-1) = %v, want %v", err, errEncode)
+	}
+	if q.Count() != 4 {
+		t.Fatalf("Count() = %d, want 4", q.Count())
+	}
+
+	q.Close()
+	var got []int
+	q.DequeueFunc(func(v int, isClose bool) bool {
+		got = append(got, v)
+		return true
+	})
+	if !slices.Equal(got, []int{1, 2, 8}) || !errors.Is(q.SpillErr(), strconv.ErrSyntax) {
+		t.Fatalf("got %v, SpillErr() = %v, want [1 2 8], %v", got, q.SpillErr(), strconv.ErrSyntax)
+	}
+	if q.Count() != 0 {
+		t.Fatalf("Count() = %d, want 0", q.Count())
+	}
+}
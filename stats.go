@@ -0,0 +1,101 @@
+package nqueue
+
+import (
+	"slices"
+	"time"
+)
+
+// Stats 是队列在某一时刻的运行统计。
+type Stats struct {
+	CreatedAt time.Time     // 队列的创建时间。
+	Age       time.Duration // 队列从创建到现在经过的时间。
+	Count     int64         // 当前待出队的元素数量。
+	InFlight  int64         // 已通过 DequeueAck 取出但尚未确认的元素数量。
+	Enqueued  uint64        // 累计入队的元素数量，包括重新投递的元素。
+	Dequeued  uint64        // 累计出队的元素数量。
+	Peak      int64         // 元素数量的历史最高值。
+	Dropped   uint64        // 累计丢弃的元素数量，参见 WithOnDrop；从队列中丢弃的元素同时计入 Dequeued。
+	DLQFailed uint64        // 被丢弃后无法放入 WithDeadLetter 设置的死信队列而最终丢失的元素数量。
+	WaitP50   time.Duration // 最近出队的元素在队列中等待时间的中位数，只有开启 WithTimestamps 时才会统计。
+	WaitP90   time.Duration // 最近出队的元素等待时间的 90 分位数。
+	WaitP99   time.Duration // 最近出队的元素等待时间的 99 分位数。
+}
+
+// waitSamples 是计算等待时间分位数时保留的最近样本数量。
+const waitSamples = 1024
+
+// Hooks 是队列的观测回调，由 WithHooks 设置。所有方法都在持有队列锁时同步调用，调用顺序与操作顺序一致，
+// 因此实现必须很快（例如只更新计数器），并且不能调用该队列的方法。
+type Hooks interface {
+	// OnEnqueue 在 n 个元素进入队列后调用，depth 为此时待出队的元素数量。重新投递和 Transfer 同样会调用，Swap 不会。
+	OnEnqueue(n int, depth int64)
+	// OnDequeue 在 n 个元素离开队列后调用，depth 为此时待出队的元素数量。
+	OnDequeue(n int, depth int64)
+	// OnClose 在队列关闭时调用一次。
+	OnClose()
+}
+
+// Stats 方法返回队列当前的运行统计，所有字段在同一次加锁中读取，彼此一致。
+// 吞吐量可以由 Enqueued、Dequeued 与 Age 计算得出。
+func (q *NQueue[T]) Stats() Stats {
+	q.recvLock.RLock()
+	defer q.recvLock.RUnlock()
+	waits := slices.Sorted(slices.Values(q.waits))
+	return Stats{
+		CreatedAt: q.createdAt,
+		Age:       time.Since(q.createdAt),
+		Count:     q.count.Load(),
+		InFlight:  q.inflight,
+		Enqueued:  q.enqueued,
+		Dequeued:  q.dequeued,
+		Peak:      q.peak,
+		Dropped:   q.dropped,
+		DLQFailed: q.dlqFailed,
+		WaitP50:   percentile(waits, 0.50),
+		WaitP90:   percentile(waits, 0.90),
+		WaitP99:   percentile(waits, 0.99),
+	}
+}
+
+// recordWait 方法记录节点 n 在队列中的等待时间，最多保留最近 waitSamples 个样本。调用方需持有 recvLock。
+func (q *NQueue[T]) recordWait(n *node[T]) {
+	wait := time.Duration(time.Now().UnixNano() - n.enqueuedAt())
+	if len(q.waits) < waitSamples {
+		q.waits = append(q.waits, wait)
+		return
+	}
+	q.waits[q.waitNext] = wait
+	q.waitNext = (q.waitNext + 1) % waitSamples
+}
+
+// percentile 函数返回已排序的样本中的 p 分位数，没有样本时返回 0。
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// CreatedAt 方法返回队列的创建时间。
+func (q *NQueue[T]) CreatedAt() time.Time {
+	return q.createdAt
+}
+
+// Age 方法返回队列从创建到现在经过的时间。
+func (q *NQueue[T]) Age() time.Duration {
+	return time.Since(q.createdAt)
+}
+
+// ResetStats 方法把 Stats 中的累计计数清零，用于在长期运行的队列上按阶段统计吞吐量。
+// Enqueued、Dequeued、Dropped 和 DLQFailed 清零，等待时间的样本被丢弃，Peak 重置为当前的元素数量；队列中的元素、Count() 和 InFlight() 不受影响。
+// 重置后 Enqueued - Dequeued 不再等于 Count。
+func (q *NQueue[T]) ResetStats() {
+	q.recvLock.Lock()
+	defer q.recvLock.Unlock()
+	q.enqueued = 0
+	q.dequeued = 0
+	q.dropped = 0
+	q.dlqFailed = 0
+	q.waits, q.waitNext = q.waits[:0], 0
+	q.peak = q.count.Load()
+}
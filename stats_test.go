@@ -0,0 +1,159 @@
+package nqueue
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// go test -run TestStats -v
+func TestStats(t *testing.T) {
+	before := time.Now()
+	q := NewNQueue[int]()
+	if q.CreatedAt().Before(before) || q.CreatedAt().After(time.Now()) {
+		t.Fatalf("CreatedAt() = %v, want between %v and now", q.CreatedAt(), before)
+	}
+
+	for i := 0; i < 5; i++ {
+		q.Enqueue(i)
+	}
+	q.Dequeue()
+	q.Dequeue()
+	_, ack, _ := q.DequeueAck()
+	q.Enqueue(5)
+
+	time.Sleep(time.Millisecond)
+	s := q.Stats()
+	if s.Count != 3 || s.InFlight != 1 || s.Enqueued != 6 || s.Dequeued != 3 || s.Peak != 5 {
+		t.Fatalf("Stats() = %+v, want Count 3, InFlight 1, Enqueued 6, Dequeued 3, Peak 5", s)
+	}
+	if !s.CreatedAt.Equal(q.CreatedAt()) || s.Age < time.Millisecond || q.Age() < s.Age {
+		t.Fatalf("Stats() CreatedAt %v, Age %v, queue Age %v", s.CreatedAt, s.Age, q.Age())
+	}
+
+	// 重新投递计为一次新的入队。
+	ack(true)
+	if s = q.Stats(); s.Enqueued != 7 || s.Count != 4 || s.InFlight != 0 {
+		t.Fatalf("Stats() after requeue = %+v, want Enqueued 7, Count 4, InFlight 0", s)
+	}
+
+	// Swap 不是入队或出队，只有 Count 反映交换后的内容。
+	other := NewNQueue[int]()
+	other.Enqueue(1)
+	q.Swap(other)
+	if s = q.Stats(); s.Enqueued != 7 || s.Dequeued != 3 || s.Count != 1 {
+		t.Fatalf("Stats() after Swap = %+v, want Enqueued 7, Dequeued 3, Count 1", s)
+	}
+	if s = other.Stats(); s.Enqueued != 1 || s.Dequeued != 0 || s.Count != 4 || s.Peak != 4 {
+		t.Fatalf("other Stats() after Swap = %+v, want Enqueued 1, Dequeued 0, Count 4, Peak 4", s)
+	}
+}
+
+// go test -run TestResetStats -v
+func TestResetStats(t *testing.T) {
+	q := NewNQueue[int]()
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+	for i := 0; i < 7; i++ {
+		q.Dequeue()
+	}
+
+	q.ResetStats()
+	if s := q.Stats(); s.Enqueued != 0 || s.Dequeued != 0 || s.Peak != 3 || s.Count != 3 {
+		t.Fatalf("Stats() after ResetStats = %+v, want Enqueued 0, Dequeued 0, Peak 3, Count 3", s)
+	}
+
+	// 下一阶段只统计重置之后的操作。
+	q.Enqueue(10)
+	q.Dequeue()
+	if s := q.Stats(); s.Enqueued != 1 || s.Dequeued != 1 || s.Peak != 4 || s.Count != 3 {
+		t.Fatalf("Stats() in next phase = %+v, want Enqueued 1, Dequeued 1, Peak 4, Count 3", s)
+	}
+	if v, ok, _ := q.Dequeue(); !ok || v != 8 {
+		t.Fatalf("Dequeue() = %d, %v, want 8, true", v, ok)
+	}
+
+	// 与并发出入队同时重置不会破坏队列内容。
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10000; i++ {
+			q.Enqueue(i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			q.ResetStats()
+		}
+	}()
+	wg.Wait()
+	if n := q.Count(); n != 10002 {
+		t.Fatalf("Count() = %d, want 10002", n)
+	}
+}
+
+// hookRecorder 记录 Hooks 的每一次调用。
+type hookRecorder struct {
+	events []string
+}
+
+func (h *hookRecorder) OnEnqueue(n int, depth int64) {
+	h.events = append(h.events, fmt.Sprintf("enqueue %d -> %d", n, depth))
+}
+
+func (h *hookRecorder) OnDequeue(n int, depth int64) {
+	h.events = append(h.events, fmt.Sprintf("dequeue %d -> %d", n, depth))
+}
+
+func (h *hookRecorder) OnClose() { h.events = append(h.events, "close") }
+
+// go test -run TestWithHooks -v
+func TestWithHooks(t *testing.T) {
+	h := &hookRecorder{}
+	q := NewNQueue(WithHooks[int](h))
+	q.Enqueue(1)
+	q.EnqueueBatch([]int{2, 3})
+	q.Dequeue()
+	q.DequeueBatch(2)
+	q.Close()
+
+	want := []string{"enqueue 1 -> 1", "enqueue 2 -> 3", "dequeue 1 -> 2", "dequeue 2 -> 0", "close"}
+	if !slices.Equal(h.events, want) {
+		t.Fatalf("events = %q, want %q", h.events, want)
+	}
+}
+
+// go test -run TestStatsWaitPercentiles -v
+func TestStatsWaitPercentiles(t *testing.T) {
+	// 未开启 WithTimestamps 时不统计等待时间。
+	plain := NewNQueue[int]()
+	plain.Enqueue(1)
+	plain.Dequeue()
+	if s := plain.Stats(); s.WaitP50 != 0 || s.WaitP99 != 0 {
+		t.Fatalf("Stats() without timestamps = %+v, want zero wait percentiles", s)
+	}
+
+	q := NewNQueue(WithTimestamps[int]())
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	q.DequeueBatch(9) // 前 9 个元素等待了至少 20ms。
+	q.Enqueue(10)
+	q.Dequeue() // 第 10 个元素几乎没有等待。
+
+	s := q.Stats()
+	if s.WaitP50 < 20*time.Millisecond || s.WaitP90 < s.WaitP50 || s.WaitP99 < s.WaitP90 {
+		t.Fatalf("Stats() = P50 %v, P90 %v, P99 %v, want P50 >= 20ms and non-decreasing", s.WaitP50, s.WaitP90, s.WaitP99)
+	}
+
+	q.ResetStats()
+	if s = q.Stats(); s.WaitP50 != 0 {
+		t.Fatalf("WaitP50 after ResetStats = %v, want 0", s.WaitP50)
+	}
+}
@@ -0,0 +1,125 @@
+package nqueue
+
+import "sync"
+
+// SubscriberPolicy 决定 Topic 的某个订阅者缓冲区已满时 Publish 的行为，在 Subscribe 时为每个订阅者单独设置。
+type SubscriberPolicy int
+
+const (
+	// SubscriberBlock 表示等待订阅者腾出空位。一个慢订阅者会拖慢 Publish，从而拖慢所有订阅者。
+	SubscriberBlock SubscriberPolicy = iota
+	// SubscriberDropOldest 表示丢弃订阅者缓冲区中最早的元素，为新元素腾出空位，参见 OverflowDropOldest。
+	SubscriberDropOldest
+	// SubscriberDisconnect 表示断开订阅者：关闭它的队列并取消订阅，订阅者取完已缓冲的元素后收到 isClose。
+	SubscriberDisconnect
+)
+
+// Topic 是一个广播主题：每个订阅者都会收到 Publish 的每一个元素的副本（事件总线语义），而不是竞争同一批元素。
+// 与 FanOut 不同，订阅者可以随时通过 Subscribe 和 Unsubscribe 加入或离开，只会收到加入之后发布的元素。
+// 每个订阅者持有一个独立的 NQueue，缓冲区大小和缓冲区已满时的策略互不影响。
+//
+// Publish 串行执行，所有订阅者看到的元素顺序与发布顺序一致。T 为指针或引用类型时各订阅者共享同一个底层对象。
+type Topic[T any] struct {
+	publish sync.Mutex                      // 串行化 Publish，保证所有订阅者看到相同的顺序。
+	lock    sync.RWMutex                    // 保护 subs 和 closed。
+	subs    map[*NQueue[T]]SubscriberPolicy // 当前的订阅者及其策略。
+	closed  bool                            // 主题是否已关闭。
+}
+
+// NewTopic 函数创建一个没有订阅者的广播主题。
+func NewTopic[T any]() *Topic[T] {
+	return &Topic[T]{subs: make(map[*NQueue[T]]SubscriberPolicy)}
+}
+
+// Subscribe 方法添加一个订阅者并返回它的队列，之后发布的每个元素都会放入这个队列。
+// buffer 为队列的容量，小于等于 0 时不限容量，此时 policy 没有作用；缓冲区已满时的行为由 policy 决定。
+// 订阅者可以直接关闭返回的队列来退订，Publish 会在下一次发布时移除它。主题已关闭时返回一个已关闭的队列。
+func (tp *Topic[T]) Subscribe(buffer int, policy SubscriberPolicy) Queue[T] {
+	var opts []Option[T]
+	if policy == SubscriberDropOldest {
+		opts = append(opts, WithOverflow[T](OverflowDropOldest))
+	}
+	q := NewNQueueWithCap(buffer, opts...)
+
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+	if tp.closed {
+		q.Close()
+		return q
+	}
+	tp.subs[q] = policy
+	return q
+}
+
+// Unsubscribe 方法移除订阅者 sub 并关闭它的队列，订阅者仍可取出已缓冲的元素。
+// 正在等待该订阅者空位的 Publish 会立即继续。sub 不是这个主题的订阅者时什么也不做。
+func (tp *Topic[T]) Unsubscribe(sub Queue[T]) {
+	q, ok := sub.(*NQueue[T])
+	if !ok {
+		return
+	}
+
+	tp.lock.Lock()
+	_, ok = tp.subs[q]
+	delete(tp.subs, q)
+	tp.lock.Unlock()
+	if ok {
+		q.Close() // 在锁外关闭，唤醒可能阻塞在这个订阅者上的 Publish。
+	}
+}
+
+// Publish 方法把值 v 的副本放入每个订阅者的队列，按各自的策略处理已满的缓冲区。
+// 已被订阅者关闭或按 SubscriberDisconnect 断开的订阅者会被移除。主题已关闭时返回 ErrQueueClosed。
+func (tp *Topic[T]) Publish(v T) error {
+	tp.publish.Lock()
+	defer tp.publish.Unlock()
+
+	tp.lock.RLock()
+	if tp.closed {
+		tp.lock.RUnlock()
+		return ErrQueueClosed
+	}
+	subs := make([]*NQueue[T], 0, len(tp.subs))
+	policies := make([]SubscriberPolicy, 0, len(tp.subs))
+	for q, policy := range tp.subs {
+		subs = append(subs, q)
+		policies = append(policies, policy)
+	}
+	tp.lock.RUnlock()
+
+	// 等待订阅者空位时不持有 lock，Unsubscribe 和 Subscribe 不会被阻塞。
+	for i, q := range subs {
+		if policies[i] == SubscriberDisconnect {
+			if !q.TryEnqueue(v) {
+				tp.Unsubscribe(q)
+			}
+			continue
+		}
+		if q.Enqueue(v) != nil {
+			tp.Unsubscribe(q) // 订阅者已关闭自己的队列。
+		}
+	}
+	return nil
+}
+
+// Subscribers 方法返回当前的订阅者数量。
+func (tp *Topic[T]) Subscribers() int {
+	tp.lock.RLock()
+	defer tp.lock.RUnlock()
+	return len(tp.subs)
+}
+
+// Close 方法关闭主题和所有订阅者的队列，之后的 Publish 返回 ErrQueueClosed。订阅者仍可取出已缓冲的元素。
+func (tp *Topic[T]) Close() {
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+	if tp.closed {
+		return
+	}
+
+	tp.closed = true
+	for q := range tp.subs {
+		q.Close()
+	}
+	tp.subs = nil
+}
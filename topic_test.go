@@ -0,0 +1,93 @@
+package nqueue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// go test -run TestTopic -v
+func TestTopic(t *testing.T) {
+	tp := NewTopic[int]()
+	all := tp.Subscribe(0, SubscriberBlock)
+	latest := tp.Subscribe(2, SubscriberDropOldest)
+	slow := tp.Subscribe(2, SubscriberDisconnect)
+
+	for i := 1; i <= 5; i++ {
+		if err := tp.Publish(i); err != nil {
+			t.Fatalf("Publish(%d) = %v", i, err)
+		}
+	}
+	late := tp.Subscribe(0, SubscriberBlock)
+	tp.Publish(6)
+
+	// 每个订阅者按各自的策略收到完整的副本；缓冲区已满的 SubscriberDisconnect 订阅者被断开。
+	cases := []struct {
+		name string
+		q    Queue[int]
+		want []int
+	}{
+		{"block", all, []int{1, 2, 3, 4, 5, 6}},
+		{"drop-oldest", latest, []int{5, 6}},
+		{"disconnect", slow, []int{1, 2}},
+		{"late", late, []int{6}},
+	}
+	for _, c := range cases {
+		if got := c.q.Drain(); !slices.Equal(got, c.want) {
+			t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+	if !slow.IsClosed() || tp.Subscribers() != 3 {
+		t.Fatalf("disconnected subscriber: IsClosed() = %v, Subscribers() = %d, want true, 3", slow.IsClosed(), tp.Subscribers())
+	}
+
+	// 订阅者自己关闭队列后，下一次 Publish 把它移除。
+	late.Close()
+	tp.Publish(7)
+	if tp.Subscribers() != 2 {
+		t.Fatalf("Subscribers() after subscriber Close = %d, want 2", tp.Subscribers())
+	}
+
+	tp.Close()
+	if err := tp.Publish(8); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Publish() after Close = %v, want ErrQueueClosed", err)
+	}
+	if v, ok, _ := all.DequeueWait(); !ok || v != 7 {
+		t.Fatalf("DequeueWait() after Close = %d, %v, want 7, true", v, ok)
+	}
+	if _, ok, isClose := all.DequeueWait(); ok || !isClose {
+		t.Fatalf("DequeueWait() on drained subscriber = %v, %v, want false, true", ok, isClose)
+	}
+	if q := tp.Subscribe(0, SubscriberBlock); !q.IsClosed() {
+		t.Fatal("Subscribe() after Close returned an open queue")
+	}
+}
+
+// go test -run TestTopicUnsubscribe -v
+func TestTopicUnsubscribe(t *testing.T) {
+	tp := NewTopic[int]()
+	full := tp.Subscribe(1, SubscriberBlock)
+	other := tp.Subscribe(0, SubscriberBlock)
+	tp.Publish(1)
+
+	// Publish 阻塞在已满的订阅者上，Unsubscribe 让它立即继续，其他订阅者仍会收到元素。
+	done := make(chan error)
+	go func() { done <- tp.Publish(2) }()
+	select {
+	case <-done:
+		t.Fatal("Publish() did not block on a full subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+	tp.Unsubscribe(full)
+	if err := <-done; err != nil {
+		t.Fatalf("Publish() = %v after Unsubscribe", err)
+	}
+
+	if got := other.Drain(); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("other subscriber got %v, want [1 2]", got)
+	}
+	if got := full.Drain(); !slices.Equal(got, []int{1}) || !full.IsClosed() || tp.Subscribers() != 1 {
+		t.Fatalf("unsubscribed: got %v, IsClosed() = %v, Subscribers() = %d", got, full.IsClosed(), tp.Subscribers())
+	}
+}
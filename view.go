@@ -0,0 +1,39 @@
+package nqueue
+
+// readOnly 是 ReadQueue 的实现，只转发出队相关的方法，不复制数据。
+type readOnly[T any] struct {
+	q Queue[T]
+}
+
+// ReadOnly 方法返回队列的只读视图，持有者只能出队，不能入队或关闭队列。
+func (q *NQueue[T]) ReadOnly() ReadQueue[T] {
+	return readOnly[T]{q: q}
+}
+
+func (r readOnly[T]) DequeueWait() (t T, ok bool, isClose bool) { return r.q.DequeueWait() }
+
+func (r readOnly[T]) DequeueFunc(fn DequeueFunc[T]) (err error) { return r.q.DequeueFunc(fn) }
+
+func (r readOnly[T]) Count() int64 { return r.q.Count() }
+
+func (r readOnly[T]) IsClosed() bool { return r.q.IsClosed() }
+
+// writeOnly 是 WriteQueue 的实现，只转发入队相关的方法，不复制数据。
+type writeOnly[T any] struct {
+	q Queue[T]
+}
+
+// WriteOnly 方法返回队列的只写视图，持有者只能入队，不能出队或关闭队列。
+func (q *NQueue[T]) WriteOnly() WriteQueue[T] {
+	return writeOnly[T]{q: q}
+}
+
+func (w writeOnly[T]) Enqueue(v T) error { return w.q.Enqueue(v) }
+
+func (w writeOnly[T]) TryEnqueue(v T) bool { return w.q.TryEnqueue(v) }
+
+func (w writeOnly[T]) Count() int64 { return w.q.Count() }
+
+func (w writeOnly[T]) Cap() int64 { return w.q.Cap() }
+
+func (w writeOnly[T]) IsClosed() bool { return w.q.IsClosed() }
@@ -0,0 +1,28 @@
+package nqueue
+
+import "time"
+
+// watchStuck 方法是 WithStuckWatchdog 的监视 goroutine，每隔 stuckAge/2 检查一次头部元素的等待时间。
+// done 为启动时的关闭通道，参见 closeOnContext。
+func (q *NQueue[T]) watchStuck(done <-chan struct{}) {
+	ticker := time.NewTicker(max(q.stuckAge/2, time.Millisecond))
+	defer ticker.Stop()
+
+	reported := false
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		age, ok := q.OldestAge()
+		switch {
+		case !ok || age < q.stuckAge:
+			reported = false // 头部元素已被取走，重新开始检测。
+		case !reported:
+			reported = true
+			q.onStuck(age)
+		}
+	}
+}
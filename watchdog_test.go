@@ -0,0 +1,77 @@
+package nqueue
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -run TestStuckWatchdog -v
+func TestStuckWatchdog(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	const d = 20 * time.Millisecond
+	var fired atomic.Int64
+	var lastAge atomic.Int64
+	q := NewNQueue(WithStuckWatchdog[int](d, func(age time.Duration) {
+		lastAge.Store(int64(age))
+		fired.Add(1)
+	}))
+
+	// 没有消费者，头部元素一直滞留。
+	q.Enqueue(1)
+	deadline := time.Now().Add(time.Second)
+	for fired.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if fired.Load() == 0 {
+		t.Fatal("watchdog did not fire for an idle consumer")
+	}
+	if age := time.Duration(lastAge.Load()); age < d {
+		t.Fatalf("reported age %v, want >= %v", age, d)
+	}
+
+	// 同一次滞留只报告一次。
+	time.Sleep(3 * d)
+	if n := fired.Load(); n != 1 {
+		t.Fatalf("watchdog fired %d times for one stall, want 1", n)
+	}
+
+	// 元素被取走后不再报告。
+	q.Dequeue()
+	time.Sleep(3 * d)
+	if n := fired.Load(); n != 1 {
+		t.Fatalf("watchdog fired %d times after drain, want 1", n)
+	}
+
+	q.Close()
+	deadline = time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base {
+		t.Fatalf("goroutines = %d, want <= %d", n, base)
+	}
+}
+
+// go test -run TestOldestAge -v
+func TestOldestAge(t *testing.T) {
+	if _, ok := NewNQueue[int]().OldestAge(); ok {
+		t.Fatal("OldestAge() on empty queue returned ok")
+	}
+
+	q := NewNQueue[int]()
+	q.Enqueue(1)
+	if _, ok := q.OldestAge(); ok {
+		t.Fatal("OldestAge() without WithTimestamps returned ok")
+	}
+
+	q = NewNQueue(WithTimestamps[int]())
+	q.Enqueue(1)
+	time.Sleep(5 * time.Millisecond)
+	q.Enqueue(2)
+	if age, ok := q.OldestAge(); !ok || age < 5*time.Millisecond {
+		t.Fatalf("OldestAge() = %v, %v, want >= 5ms, true", age, ok)
+	}
+}